@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+// setEnv sets the environment variables for the test only
+func setEnv(t *testing.T, env map[string]string) {
+	for key, value := range env {
+		previous, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, previous)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func TestGetConfiguration_RejectsDevelopmentSettingsInProduction(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"any cors origin", map[string]string{corsOrigins: "*"}},
+		{"null cors origin", map[string]string{corsOrigins: "https://app.example.com,null"}},
+		{"insecure session cookie", map[string]string{sessionCookieSec: "false"}},
+		{"in memory users", map[string]string{userStorage: "memory"}},
+		{"in memory sessions", map[string]string{sessionStorage: "memory"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env[environment] = ProductionEnv
+			setEnv(t, tt.env)
+
+			if _, err := GetConfiguration(); err == nil {
+				t.Error("expected the configuration to be rejected")
+			}
+		})
+	}
+}
+
+func TestGetConfiguration_EnvironmentDefaults(t *testing.T) {
+	setEnv(t, map[string]string{environment: ProductionEnv})
+	production, err := GetConfiguration()
+	if err != nil {
+		t.Fatalf("expected the production defaults to be valid, got %v", err)
+	}
+	if len(production.CORSAllowedOrigins) != 0 || !production.SessionCookieSecure || production.HSTSMaxAge == 0 {
+		t.Errorf("expected closed defaults in production, got origins %v, secure cookie %v, hsts %s",
+			production.CORSAllowedOrigins, production.SessionCookieSecure, production.HSTSMaxAge)
+	}
+
+	setEnv(t, map[string]string{environment: DevelopmentEnv})
+	development, err := GetConfiguration()
+	if err != nil {
+		t.Fatalf("expected the development defaults to be valid, got %v", err)
+	}
+	if len(development.CORSAllowedOrigins) == 0 || development.SessionCookieSecure || development.HSTSMaxAge != 0 {
+		t.Errorf("expected local defaults in development, got origins %v, secure cookie %v, hsts %s",
+			development.CORSAllowedOrigins, development.SessionCookieSecure, development.HSTSMaxAge)
+	}
+}
+
+func TestGetConfiguration_RedisModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		valid bool
+	}{
+		{"single", map[string]string{redisMode: RedisSingle}, true},
+		{"sentinel", map[string]string{redisMode: RedisSentinel, redisMasterName: "mymaster", redisSentinelAddrs: "s1:26379,s2:26379"}, true},
+		{"sentinel without master", map[string]string{redisMode: RedisSentinel, redisSentinelAddrs: "s1:26379"}, false},
+		{"sentinel without addresses", map[string]string{redisMode: RedisSentinel, redisMasterName: "mymaster"}, false},
+		{"cluster", map[string]string{redisMode: RedisCluster, redisClusterAddrs: "c1:6379,c2:6379,c3:6379"}, true},
+		{"cluster without addresses", map[string]string{redisMode: RedisCluster}, false},
+		{"cluster with a db", map[string]string{redisMode: RedisCluster, redisClusterAddrs: "c1:6379", redisDB: "1"}, false},
+		{"unknown mode", map[string]string{redisMode: "ring"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env[environment] = DevelopmentEnv
+			setEnv(t, tt.env)
+
+			_, err := GetConfiguration()
+			if tt.valid && err != nil {
+				t.Errorf("expected the configuration to be valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected the configuration to be rejected")
+			}
+		})
+	}
+}
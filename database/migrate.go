@@ -2,51 +2,97 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"home24-technical-test/config"
-	"log"
 
 	rice "github.com/GeertJohan/go.rice"
+	"github.com/go-sql-driver/mysql"
 	"github.com/golang-migrate/migrate"
+	migratedatabase "github.com/golang-migrate/migrate/database"
+	migratemysql "github.com/golang-migrate/migrate/database/mysql"
 	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/golang-migrate/migrate/database/sqlite3"
 )
 
-// MigrateUp migrates the database up
-func MigrateUp(cfg *config.Config) {
-	// Setup the database
+// MigrateUp migrates the database up with the migrations of its dialect.
+//
+// The migrations don't use the pool of the app, they run on a connection of their own closed before the app starts:
+// the migrate drivers hold a connection of the database they are given until they are closed, closing them
+// closes that database too, and the mysql migrations need the multi statements the app doesn't allow
+func MigrateUp(cfg *config.Config) error {
+	dialect, dataSourceName, err := ParseConnectionString(cfg.DBConnectionString)
+	if err != nil {
+		return fmt.Errorf("error when parsing the database connection string: %v", err)
+	}
+
+	// the mysql migrations may hold several statements
+	if dialect == MySQL {
+		mysqlConfig, err := mysql.ParseDSN(dataSourceName)
+		if err != nil {
+			return fmt.Errorf("error when parsing the mysql connection string: %v", err)
+		}
+		mysqlConfig.MultiStatements = true
+		dataSourceName = mysqlConfig.FormatDSN()
+	}
+
+	// Setup the database, a single connection
 	//
-	db, err := sql.Open("postgres", cfg.DBConnectionString)
+	db, err := sql.Open(dialect, dataSourceName)
 	if err != nil {
-		log.Fatal("error when open postgres connection: ", err)
+		return fmt.Errorf("error when open %s connection: %v", dialect, err)
 	}
+	db.SetMaxOpenConns(1)
 
-	// Setup the source driver
+	// Setup the source driver, the boxes are found by their literal path
 	//
-	sourceDriver := &RiceBoxSource{}
-	sourceDriver.PopulateMigrations(rice.MustFindBox("./migrations"))
+	var box *rice.Box
+	switch dialect {
+	case Postgres:
+		box, err = rice.FindBox("./migrations/postgres")
+	case MySQL:
+		box, err = rice.FindBox("./migrations/mysql")
+	case SQLite:
+		box, err = rice.FindBox("./migrations/sqlite")
+	}
 	if err != nil {
-		log.Fatal("error when creating source driver: ", err)
+		db.Close()
+		return fmt.Errorf("error when finding the %s migrations: %v", dialect, err)
+	}
+
+	sourceDriver := &RiceBoxSource{}
+	if err := sourceDriver.PopulateMigrations(box); err != nil {
+		db.Close()
+		return fmt.Errorf("error when creating source driver: %v", err)
 	}
 
 	// Setup the database driver
 	//
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	var driver migratedatabase.Driver
+	switch dialect {
+	case Postgres:
+		driver, err = postgres.WithInstance(db, &postgres.Config{})
+	case MySQL:
+		driver, err = migratemysql.WithInstance(db, &migratemysql.Config{})
+	case SQLite:
+		driver, err = sqlite3.WithInstance(db, &sqlite3.Config{})
+	}
 	if err != nil {
-		log.Fatal("error when creating postgres instance: ", err)
+		db.Close()
+		return fmt.Errorf("error when creating %s instance: %v", dialect, err)
 	}
 
 	m, err := migrate.NewWithInstance(
 		"go.rice", sourceDriver,
-		"postgres", driver)
-
+		dialect, driver)
 	if err != nil {
-		log.Fatal("error when creating database instance: ", err)
+		driver.Close()
+		return fmt.Errorf("error when creating database instance: %v", err)
 	}
+	defer m.Close()
 
-	if err := m.Up(); err != nil {
-		if err.Error() != "no change" {
-			log.Fatal("error when migrate up: ", err)
-		}
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("error when migrate up: %v", err)
 	}
 
-	defer m.Close()
+	return nil
 }
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"home24-technical-test/config"
+)
+
+func TestMigrateUp(t *testing.T) {
+	cfg := &config.Config{DBConnectionString: "sqlite://" + filepath.Join(t.TempDir(), "users.db")}
+	if err := MigrateUp(cfg); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	// an up to date database is not an error
+	if err := MigrateUp(cfg); err != nil {
+		t.Errorf("failed to migrate again: %v", err)
+	}
+
+	for _, connectionString := range []string{
+		"oracle://localhost/users",
+		"sqlite://" + filepath.Join(t.TempDir(), "missing", "users.db"),
+	} {
+		if err := MigrateUp(&config.Config{DBConnectionString: connectionString}); err == nil {
+			t.Errorf("expected migrating %s to fail", connectionString)
+		}
+	}
+}
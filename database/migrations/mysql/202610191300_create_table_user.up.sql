@@ -0,0 +1,29 @@
+create table `user`
+(
+	`id` int not null auto_increment,
+	`name` varchar(100) not null,
+	`email` varchar(100) not null,
+	`address` varchar(255) not null,
+	`password` varchar(255) null,
+	`createdBy` int not null,
+	`createdAt` datetime(6) not null,
+	`updatedBy` int not null,
+	`updatedAt` datetime(6) not null,
+	`deletedBy` int null,
+	`deletedAt` datetime(6) null,
+	`version` int not null default 1,
+	`phoneNumber` varchar(20) not null default '',
+	`locale` varchar(35) not null default '',
+	`timezone` varchar(64) not null default '',
+	`marketingEmailConsent` boolean not null default false,
+	`marketingSmsConsent` boolean not null default false,
+	`avatar` varchar(255) not null default '',
+	`customAttributes` json not null,
+	-- mysql has no partial index, the email is only kept here while the user is active
+	`activeEmail` varchar(100) generated always as (if(`deletedAt` is null, lower(`email`), null)) stored,
+	constraint user_pkey primary key (`id`),
+	unique key user_email_lower_key (`activeEmail`),
+	key user_name_id_idx (`name`, `id`),
+	key user_email_id_idx (`email`, `id`),
+	key user_created_at_id_idx (`createdAt`, `id`)
+) engine = InnoDB default charset = utf8mb4;
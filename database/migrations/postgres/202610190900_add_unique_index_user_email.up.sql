@@ -0,0 +1,25 @@
+-- active users whose emails only differ by case or surrounding spaces can't be merged safely,
+-- they have to be resolved by hand before the unique index can be created
+do $$
+declare
+	duplicates text;
+begin
+	select string_agg(format('%s (ids %s)', "email", "ids"), ', ')
+	into duplicates
+	from (
+		select lower(trim("email")) as "email", string_agg("id"::text, ', ' order by "id") as "ids"
+		from public."user"
+		where "deletedAt" is null
+		group by lower(trim("email"))
+		having count(*) > 1
+	) as duplicated;
+
+	if duplicates is not null then
+		raise exception 'cannot add the unique index on lower(email), active users share an email: %. Delete or rename the duplicates and run the migration again', duplicates;
+	end if;
+end
+$$;
+
+update public."user" set "email" = lower(trim("email"));
+
+create unique index user_email_lower_key on public."user" (lower("email")) where "deletedAt" is null;
@@ -0,0 +1,5 @@
+create index user_name_id_idx on public."user" ("name", "id");
+
+create index user_email_id_idx on public."user" ("email", "id");
+
+create index user_created_at_id_idx on public."user" ("createdAt", "id");
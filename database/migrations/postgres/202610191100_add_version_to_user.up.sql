@@ -0,0 +1 @@
+alter table public."user" add column "version" int not null default 1;
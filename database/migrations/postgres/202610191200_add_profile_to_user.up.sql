@@ -0,0 +1,8 @@
+alter table public."user"
+	add column "phoneNumber" varchar(20) not null default '',
+	add column "locale" varchar(35) not null default '',
+	add column "timezone" varchar(64) not null default '',
+	add column "marketingEmailConsent" boolean not null default false,
+	add column "marketingSmsConsent" boolean not null default false,
+	add column "avatar" varchar(255) not null default '',
+	add column "customAttributes" jsonb not null default '{}'::jsonb;
@@ -0,0 +1,14 @@
+create table public."session"
+(
+	"token" varchar(255) not null,
+	"type" varchar(50) not null,
+	"userId" int,
+	"expiredAt" timestamptz not null,
+	"info" jsonb not null default '{}'::jsonb,
+	"user" jsonb,
+	constraint session_pkey primary key ("type", "token")
+);
+
+create index session_user_id_idx on public."session" ("userId");
+
+create index session_expired_at_idx on public."session" ("expiredAt");
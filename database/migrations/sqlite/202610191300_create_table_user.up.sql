@@ -0,0 +1,30 @@
+create table "user"
+(
+	"id" integer primary key autoincrement,
+	"name" varchar(100) not null,
+	"email" varchar(100) not null,
+	"address" varchar(255) not null,
+	"password" varchar(255) null,
+	"createdBy" int not null,
+	"createdAt" timestamp not null,
+	"updatedBy" int not null,
+	"updatedAt" timestamp not null,
+	"deletedBy" int,
+	"deletedAt" timestamp,
+	"version" int not null default 1,
+	"phoneNumber" varchar(20) not null default '',
+	"locale" varchar(35) not null default '',
+	"timezone" varchar(64) not null default '',
+	"marketingEmailConsent" boolean not null default false,
+	"marketingSmsConsent" boolean not null default false,
+	"avatar" varchar(255) not null default '',
+	"customAttributes" text not null default '{}'
+);
+
+create unique index user_email_lower_key on "user" (lower("email")) where "deletedAt" is null;
+
+create index user_name_id_idx on "user" ("name", "id");
+
+create index user_email_id_idx on "user" ("email", "id");
+
+create index user_created_at_id_idx on "user" ("createdAt", "id");
@@ -1,6 +1,7 @@
 package seeder
 
 import (
+	"fmt"
 	"log"
 	"sort"
 	"strconv"
@@ -29,49 +30,53 @@ type fileData struct {
 
 var allSeeder = []Seed{}
 
-// SeedUp is function to Seed Seeder Up
-func SeedUp(dbConnectionString string, isDevelopment bool) {
+// SeedUp is function to Seed Seeder Up on the postgres database of the app,
+// the errors are returned so the app closes what it opened before exiting
+func SeedUp(storage *sqlx.DB, isDevelopment bool) error {
 	now := time.Now()
 
-	storage, err := sqlx.Open("postgres", dbConnectionString)
-	if err != nil {
-		log.Fatalln("failed to open database x: ", err)
-	}
-	if err = storage.Ping(); err != nil {
-		log.Fatalln("failed to connect to database :", err)
-	}
-	defer storage.Close()
-
 	addAllSeeder()
 	addStagingSeeder(isDevelopment)
+	if len(allSeeder) == 0 {
+		return nil
+	}
 
 	sort.SliceStable(allSeeder, func(i, j int) bool {
 		return allSeeder[i].version < allSeeder[j].version
 	})
 
-	s := findOrCreateSchemaSeeder(storage)
+	s, err := findOrCreateSchemaSeeder(storage)
+	if err != nil {
+		return err
+	}
 
 	if s == nil {
 		s = &schemaSeeder{}
-		initSchema(storage)
+		if err := initSchema(storage); err != nil {
+			return err
+		}
 	}
 
-	newestVersion := getSchemaVersion(allSeeder[len(allSeeder)-1].version)
+	newestVersion, err := getSchemaVersion(allSeeder[len(allSeeder)-1].version)
+	if err != nil {
+		return err
+	}
 	if s.Version == newestVersion {
 		log.Println("Seeder is on Same Meta Version")
-		return
+		return nil
 	}
 
 	var oldVersion = s.Version
 	for i, seed := range allSeeder {
-		version := getSchemaVersion(seed.version)
-		if s.Version == version && s.Dirty == true {
-			s.Version = version
-			_ = sqlx.MustExec(storage, seed.content)
-			s.Dirty = false
-		} else if s.Version < version && s.Dirty == false {
+		version, err := getSchemaVersion(seed.version)
+		if err != nil {
+			return err
+		}
+		if (s.Version == version && s.Dirty) || (s.Version < version && !s.Dirty) {
 			s.Version = version
-			_ = sqlx.MustExec(storage, seed.content)
+			if _, err := storage.Exec(seed.content); err != nil {
+				return fmt.Errorf("seed %s: %w", seed.version, err)
+			}
 			s.Dirty = false
 		}
 		if i == len(allSeeder)-1 {
@@ -80,36 +85,37 @@ func SeedUp(dbConnectionString string, isDevelopment bool) {
 	}
 
 	if oldVersion < s.Version {
-		updateSchema(storage, oldVersion, s)
+		if err := updateSchema(storage, oldVersion, s); err != nil {
+			return err
+		}
 	} else if oldVersion > s.Version {
-		log.Fatalf("Missing Version, Version on DB %d, latest Version exist %d", oldVersion, s.Version)
+		return fmt.Errorf("missing seed version, version on db %d, latest version %d", oldVersion, s.Version)
 	}
 
 	log.Printf("Seeding took %v", time.Since(now))
+	return nil
 }
 
-func getSchemaVersion(version string) int {
-	var result int
-	if n, err := strconv.Atoi(version); err == nil {
-		result = n
-	} else {
-		log.Fatal(version, " is not an integer.")
+func getSchemaVersion(version string) (int, error) {
+	n, err := strconv.Atoi(version)
+	if err != nil {
+		return 0, fmt.Errorf("seed version %q is not an integer: %w", version, err)
 	}
-	return result
+	return n, nil
 }
 
-func initSchema(db *sqlx.DB) {
+func initSchema(db *sqlx.DB) error {
 	query := `
 	INSERT INTO schema_seeders ("version", "dirty") VALUES
 	(0, false);`
 
-	_, err := db.Exec(query)
-	if err != nil {
-		log.Fatal(err)
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to init the seeder schema: %w", err)
 	}
+	return nil
 }
 
-func updateSchema(db *sqlx.DB, oldVersion int, schema *schemaSeeder) {
+func updateSchema(db *sqlx.DB, oldVersion int, schema *schemaSeeder) error {
 	query := `
 		UPDATE schema_seeders SET
 		"version" = $1,
@@ -117,42 +123,45 @@ func updateSchema(db *sqlx.DB, oldVersion int, schema *schemaSeeder) {
 		WHERE "version" = $3;
 	`
 
-	_, err := db.Exec(query, schema.Version, schema.Dirty, oldVersion)
-	if err != nil {
-		log.Fatal(err)
+	if _, err := db.Exec(query, schema.Version, schema.Dirty, oldVersion); err != nil {
+		return fmt.Errorf("failed to update the seeder schema: %w", err)
 	}
+	return nil
 }
 
-func findOrCreateSchemaSeeder(db *sqlx.DB) *schemaSeeder {
+// findOrCreateSchemaSeeder finds the seeder schema, creating its table when missing.
+// It's nil when the table has no row yet
+func findOrCreateSchemaSeeder(db *sqlx.DB) (*schemaSeeder, error) {
 	flag := true
 	dest := &schemaSeeder{}
 	err := db.Get(dest, `SELECT version, dirty FROM schema_seeders LIMIT 1`)
 	if err != nil {
-		_, isErrPQ := err.(*pq.Error)
+		pqErr, isErrPQ := err.(*pq.Error)
 		if isErrPQ {
-			if err.(*pq.Error).Code == "42P01" {
+			if pqErr.Code == "42P01" {
 				// ignore
 				qry := `
 					CREATE TABLE public.schema_seeders(
 						"version" BIGINT NOT NULL UNIQUE,
 						"dirty" BOOL NOT NULL);`
-				_, err = db.Exec(qry)
-				if err != nil {
-					log.Fatal(err)
+				if _, err = db.Exec(qry); err != nil {
+					return nil, fmt.Errorf("failed to create the seeder schema: %w", err)
 				}
 				flag = false
+			} else {
+				return nil, fmt.Errorf("failed to read the seeder schema: %w", err)
 			}
 		} else {
-			return nil
+			return nil, nil
 		}
 	}
 	if !flag {
 		err = db.Get(dest, `SELECT version, dirty FROM schema_seeders LIMIT 1`)
 		if err != nil {
-			return nil
+			return nil, nil
 		}
 	}
-	return dest
+	return dest, nil
 }
 
 // // Append data should on order by timestamps created
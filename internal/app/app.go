@@ -0,0 +1,554 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"home24-technical-test/config"
+	"home24-technical-test/database"
+	"home24-technical-test/database/seeder"
+	internalgrpc "home24-technical-test/internal/grpc"
+	internalhttp "home24-technical-test/internal/http"
+	"home24-technical-test/internal/http/controller"
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/service"
+	userStorageCache "home24-technical-test/internal/user/storage/cache"
+	userStorageMemory "home24-technical-test/internal/user/storage/memory"
+	userStorageMySQL "home24-technical-test/internal/user/storage/mysql"
+	userStoragePostgres "home24-technical-test/internal/user/storage/postgres"
+	userStorageRedis "home24-technical-test/internal/user/storage/redis"
+	userStorageSQLite "home24-technical-test/internal/user/storage/sqlite"
+	"home24-technical-test/pkg/blobstore"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/http/ratelimit"
+	"home24-technical-test/pkg/lifecycle"
+
+	"github.com/go-redis/redis"
+	"github.com/jmoiron/sqlx"
+	"google.golang.org/grpc"
+)
+
+// Option replaces a dependency the app would build from the configuration
+type Option func(a *App)
+
+// WithConfig uses the configuration instead of the one of the environment
+func WithConfig(cfg *config.Config) Option {
+	return func(a *App) {
+		a.config = cfg
+	}
+}
+
+// WithDB uses the database instead of opening the configured one, it's neither migrated nor closed by the app
+func WithDB(db *sqlx.DB) Option {
+	return func(a *App) {
+		a.db = db
+	}
+}
+
+// WithRedis uses the redis client instead of connecting to the configured one, it's not closed by the app
+func WithRedis(redisClient redis.UniversalClient) Option {
+	return func(a *App) {
+		a.redisClient = redisClient
+	}
+}
+
+// WithUserStorage stores the users in the storage instead of Postgres, no database is needed then
+func WithUserStorage(storage user.Storage) Option {
+	return func(a *App) {
+		a.userStorage = storage
+	}
+}
+
+// WithSessionStorage stores the sessions in the storage instead of Redis, it's not cached
+func WithSessionStorage(storage user.SessionStorage) Option {
+	return func(a *App) {
+		a.sessionStorage = storage
+	}
+}
+
+// WithAvatarStore stores the avatars in the store instead of the FILE_STORAGE directory
+func WithAvatarStore(store blobstore.Store) Option {
+	return func(a *App) {
+		a.avatarStore = store
+	}
+}
+
+// WithRateLimiter keeps the rate limit buckets in the limiter instead of the configured backend
+func WithRateLimiter(limiter ratelimit.Limiter) Option {
+	return func(a *App) {
+		a.rateLimiter = limiter
+	}
+}
+
+// App is the user service with all its dependencies
+type App struct {
+	config         *config.Config
+	db             *sqlx.DB
+	redisClient    redis.UniversalClient
+	userStorage    user.Storage
+	sessionStorage user.SessionStorage
+	avatarStore    blobstore.Store
+	rateLimiter    ratelimit.Limiter
+
+	service *service.Service
+	server  *internalhttp.Server
+	// subscribeRevoked keeps the session cache in sync with the other instances, nil without cache
+	subscribeRevoked func(ctx context.Context) error
+	// sessionReaper deletes the expired database sessions, nil unless the sessions are kept in the database
+	sessionReaper *userStoragePostgres.SessionReaper
+	// closers close the connections opened by the app, in order
+	closers []closer
+}
+
+// closer is a connection opened by the app
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Config is the configuration of the app
+func (a *App) Config() *config.Config {
+	return a.config
+}
+
+// Service is the user application service, e.g. to create the users of a test
+func (a *App) Service() service.ServiceInterface {
+	return a.service
+}
+
+// Handler is the http handler serving all the routes
+func (a *App) Handler() http.Handler {
+	return a.server.Handler()
+}
+
+// Start listens on the configured ports and hands the servers, the background workers
+// and the connections opened by the app to the lifecycle manager
+func (a *App) Start(lc *lifecycle.Manager) error {
+	purger := service.NewPurger(a.service, a.config.UserRetention, a.config.UserPurgeInterval)
+	lc.Go("deleted users purger", func(ctx context.Context) error {
+		purger.Run(ctx)
+		return nil
+	})
+	if a.subscribeRevoked != nil {
+		lc.Go("revoked sessions subscriber", a.subscribeRevoked)
+	}
+	if a.sessionReaper != nil {
+		lc.Go("expired sessions reaper", func(ctx context.Context) error {
+			a.sessionReaper.Run(ctx)
+			return nil
+		})
+	}
+
+	for _, c := range a.closers {
+		lc.OnClose(c.name, c.close)
+	}
+	a.closers = nil
+
+	return a.server.Start(lc)
+}
+
+// Close closes the connections opened by the app, the started apps are closed by the lifecycle manager
+func (a *App) Close() error {
+	var firstErr error
+	for _, c := range a.closers {
+		if err := c.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	a.closers = nil
+	return firstErr
+}
+
+// New builds the app from the configuration, the options replace the dependencies it would build
+func New(opts ...Option) (*App, error) {
+	a := &App{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if err := a.build(); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *App) build() error {
+	var err error
+	if a.config == nil {
+		if a.config, err = config.GetConfiguration(); err != nil {
+			return fmt.Errorf("failed to get configuration: %v", err)
+		}
+	}
+
+	if a.userStorage == nil {
+		if err := a.newUserStorage(); err != nil {
+			return err
+		}
+	}
+
+	if a.sessionStorage == nil {
+		if err := a.newSessionStorage(); err != nil {
+			return err
+		}
+	}
+
+	if a.avatarStore == nil {
+		a.avatarStore = blobstore.NewLocalStore(a.config.FileStorage)
+	}
+
+	attributeSchema, err := user.ParseAttributeSchema(a.config.UserAttributesSchema)
+	if err != nil {
+		return err
+	}
+
+	a.service = service.NewService(
+		user.NewService(
+			a.userStorage,
+			a.avatarStore,
+			attributeSchema,
+			a.config.AvatarMaxSize,
+		),
+		user.NewSessionService(
+			a.sessionStorage,
+			a.config.AdminEmails,
+		),
+	)
+
+	adapters := adapter.NewAdapters(a.service)
+	// a nil database runs the transactions of the non sql storages as is
+	dataManager := data.NewManager(a.db)
+
+	rateLimits, err := a.rateLimits()
+	if err != nil {
+		return err
+	}
+
+	grpcRateLimits := internalgrpc.RateLimits{
+		Limiter: rateLimits.Limiter,
+		Login:   rateLimits.Login,
+	}
+
+	sessionCookie, err := newSessionCookie(a.config)
+	if err != nil {
+		return err
+	}
+
+	a.server, err = internalhttp.NewServer(adapters, dataManager, internalhttp.Settings{
+		ExposingPort:     a.config.ExposingPort,
+		ExposingGRPCPort: a.config.ExposingGRPCPort,
+		// built by the http server, over the same tls
+		NewGRPCServer: func(opts ...grpc.ServerOption) *grpc.Server {
+			return internalgrpc.NewServer(adapters, dataManager, grpcRateLimits, opts...)
+		},
+		ClientCredentials: internalhttp.ClientCredentials{
+			Clients: a.config.IntrospectionClients,
+			APIKeys: a.config.IntrospectionAPIKeys,
+		},
+		RateLimits:    rateLimits,
+		SessionCookie: sessionCookie,
+		CORSPolicy: internalhttp.CORSPolicy{
+			AllowedOrigins:   a.config.CORSAllowedOrigins,
+			AllowedMethods:   a.config.CORSAllowedMethods,
+			AllowedHeaders:   a.config.CORSAllowedHeaders,
+			AllowCredentials: a.config.CORSAllowCredentials,
+			MaxAge:           a.config.CORSMaxAge,
+		},
+		SecurityHeaders: internalhttp.SecurityHeaders{
+			HSTSMaxAge:                a.config.HSTSMaxAge,
+			ContentSecurityPolicy:     a.config.ContentSecurityPolicy,
+			HTMLContentSecurityPolicy: a.config.HTMLContentSecurityPolicy,
+			ReferrerPolicy:            a.config.ReferrerPolicy,
+		},
+		TLS: internalhttp.TLS{
+			CertFile:          a.config.TLSCertFile,
+			KeyFile:           a.config.TLSKeyFile,
+			ClientCAFile:      a.config.TLSClientCAFile,
+			RequireClientCert: a.config.TLSRequireClientCert,
+			ServiceIdentities: a.config.TLSServiceIdentities,
+			ReloadInterval:    a.config.TLSReloadInterval,
+			RedirectPort:      a.config.HTTPRedirectPort,
+		},
+		Timeouts: internalhttp.Timeouts{
+			Read:       a.config.HTTPReadTimeout,
+			ReadHeader: a.config.HTTPReadHeaderTimeout,
+			Write:      a.config.HTTPWriteTimeout,
+			Idle:       a.config.HTTPIdleTimeout,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create the server: %v", err)
+	}
+
+	return nil
+}
+
+// newUserStorage builds the user storage of the configured backend, the database storage follows the dialect of the database
+func (a *App) newUserStorage() error {
+	switch a.config.UserStorageBackend {
+	case "database":
+		if err := a.openDB(); err != nil {
+			return err
+		}
+		switch a.db.DriverName() {
+		case database.Postgres:
+			a.userStorage = userStoragePostgres.NewPostgresStorage(a.db)
+		case database.MySQL:
+			a.userStorage = userStorageMySQL.NewMySQLStorage(a.db)
+		case database.SQLite:
+			a.userStorage = userStorageSQLite.NewSQLiteStorage(a.db)
+		default:
+			return fmt.Errorf("unsupported database dialect %q", a.db.DriverName())
+		}
+		// the postgres database is seeded by its seeder
+		if a.db.DriverName() != database.Postgres && a.config.IsDevelopment {
+			if err := seedDevelopmentUser(a.userStorage); err != nil {
+				return err
+			}
+		}
+	case "memory":
+		a.userStorage = userStorageMemory.NewMemoryStorage()
+		if a.config.IsDevelopment {
+			if err := seedDevelopmentUser(a.userStorage); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown user storage backend %q", a.config.UserStorageBackend)
+	}
+
+	return nil
+}
+
+// seedDevelopmentUser inserts the user of the development seed of the postgres database unless it exists
+func seedDevelopmentUser(storage user.Storage) error {
+	ctx := context.Background()
+	const email = "user@home24.com"
+
+	if _, err := storage.FindByEmail(ctx, email); err == nil {
+		return nil
+	} else if !errors.Is(err, user.ErrNotFound) {
+		return fmt.Errorf("failed to seed the users: %v", err)
+	}
+
+	err := storage.Insert(ctx, &model.User{
+		Name:     "user",
+		Email:    email,
+		Address:  "Jakarta",
+		Password: "$2a$10$5.p1ONDftoudtkvcl/o30u.BMYbEzCfdGEqrOVz6fnXsRmRir0bGK",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to seed the users: %v", err)
+	}
+
+	return nil
+}
+
+// newSessionStorage builds the session storage of the configured backend, the redis sessions are cached in process.
+// The database sessions are not cached, the other instances couldn't revoke the cached ones
+func (a *App) newSessionStorage() error {
+	switch a.config.SessionStorageBackend {
+	case "redis":
+		if err := a.connectRedis(); err != nil {
+			return err
+		}
+
+		redisSessionStorage := userStorageRedis.NewSessionStorage(a.redisClient)
+		a.sessionStorage = redisSessionStorage
+		if a.config.SessionCacheTTL > 0 {
+			cachedSessionStorage := userStorageCache.NewSessionStorage(redisSessionStorage, a.config.SessionCacheTTL)
+			// sessions deleted by the other instances must not be served from the cache
+			a.subscribeRevoked = func(ctx context.Context) error {
+				return redisSessionStorage.SubscribeRevoked(ctx, cachedSessionStorage.Revoke)
+			}
+			a.sessionStorage = cachedSessionStorage
+		}
+	case "database":
+		if err := a.openDB(); err != nil {
+			return err
+		}
+		if a.db.DriverName() != database.Postgres {
+			return fmt.Errorf("the database session storage needs postgres, got %q", a.db.DriverName())
+		}
+
+		postgresSessionStorage := userStoragePostgres.NewSessionStorage(a.db)
+		a.sessionReaper = userStoragePostgres.NewSessionReaper(postgresSessionStorage, a.config.SessionReapInterval)
+		a.sessionStorage = postgresSessionStorage
+	case "memory":
+		a.sessionStorage = userStorageMemory.NewSessionStorage()
+	default:
+		return fmt.Errorf("unknown session storage backend %q", a.config.SessionStorageBackend)
+	}
+
+	return nil
+}
+
+// openDB opens, migrates and seeds the configured database unless one was given.
+// The storages, the seeder & the transactions share its pool, the migrations run on a connection of their own
+func (a *App) openDB() error {
+	if a.db != nil {
+		return nil
+	}
+
+	db, err := database.Open(a.config.DBConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to open database x: %v", err)
+	}
+	db.SetMaxOpenConns(a.config.DBMaxOpenConns)
+	db.SetMaxIdleConns(a.config.DBMaxIdleConns)
+	db.SetConnMaxLifetime(a.config.DBConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.DBConnectTimeout)
+	defer cancel()
+	if err := database.WaitUntilReady(ctx, db); err != nil {
+		db.Close()
+		return err
+	}
+
+	// Migrate the db, the seeds are written for postgres
+	if err := database.MigrateUp(a.config); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+	if db.DriverName() == database.Postgres {
+		if err := seeder.SeedUp(db, a.config.IsDevelopment); err != nil {
+			db.Close()
+			return fmt.Errorf("failed to seed database: %v", err)
+		}
+	}
+	a.db = db
+
+	a.closers = append(a.closers, closer{name: "database", close: db.Close})
+	return nil
+}
+
+// connectRedis connects to the configured redis unless a client was given.
+// Redis is closed before the database, nothing uses it once the servers & the workers are stopped
+func (a *App) connectRedis() error {
+	if a.redisClient != nil {
+		return nil
+	}
+
+	redisClient := newRedisClient(a.config)
+	if _, err := redisClient.Ping().Result(); err != nil {
+		redisClient.Close()
+		return fmt.Errorf("failed to connect to redis: %v", err)
+	}
+	a.redisClient = redisClient
+
+	a.closers = append([]closer{{name: "redis", close: redisClient.Close}}, a.closers...)
+	return nil
+}
+
+// newRedisClient creates the client of the configured redis mode, a single node, the master of the sentinels or a cluster
+func newRedisClient(cfg *config.Config) redis.UniversalClient {
+	switch cfg.RedisMode {
+	case config.RedisSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.RedisMasterName,
+			SentinelAddrs: cfg.RedisSentinelAddrs,
+			Password:      cfg.RedisPassword,
+			DB:            cfg.RedisDB,
+			MaxRetries:    cfg.RedisMaxRetries,
+			DialTimeout:   cfg.RedisDialTimeout,
+			ReadTimeout:   cfg.RedisReadTimeout,
+			WriteTimeout:  cfg.RedisWriteTimeout,
+			PoolSize:      cfg.RedisPoolSize,
+			MinIdleConns:  cfg.RedisMinIdleConns,
+			PoolTimeout:   cfg.RedisPoolTimeout,
+		})
+	case config.RedisCluster:
+		// the pool settings apply to every node of the cluster
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        cfg.RedisClusterAddrs,
+			Password:     cfg.RedisPassword,
+			MaxRetries:   cfg.RedisMaxRetries,
+			DialTimeout:  cfg.RedisDialTimeout,
+			ReadTimeout:  cfg.RedisReadTimeout,
+			WriteTimeout: cfg.RedisWriteTimeout,
+			PoolSize:     cfg.RedisPoolSize,
+			MinIdleConns: cfg.RedisMinIdleConns,
+			PoolTimeout:  cfg.RedisPoolTimeout,
+		})
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:         cfg.RedisAddr,
+		Password:     cfg.RedisPassword,
+		DB:           cfg.RedisDB,
+		MaxRetries:   cfg.RedisMaxRetries,
+		DialTimeout:  cfg.RedisDialTimeout,
+		ReadTimeout:  cfg.RedisReadTimeout,
+		WriteTimeout: cfg.RedisWriteTimeout,
+		PoolSize:     cfg.RedisPoolSize,
+		MinIdleConns: cfg.RedisMinIdleConns,
+		PoolTimeout:  cfg.RedisPoolTimeout,
+	})
+}
+
+// rateLimits builds the rate limits of the configured backend & policies
+func (a *App) rateLimits() (internalhttp.RateLimits, error) {
+	var rateLimits internalhttp.RateLimits
+
+	switch {
+	case a.rateLimiter != nil:
+		rateLimits.Limiter = a.rateLimiter
+	case a.config.RateLimitBackend == "none":
+		return rateLimits, nil
+	case a.config.RateLimitBackend == "memory":
+		rateLimits.Limiter = ratelimit.NewMemoryLimiter()
+	case a.config.RateLimitBackend == "redis":
+		if err := a.connectRedis(); err != nil {
+			return rateLimits, err
+		}
+		rateLimits.Limiter = ratelimit.NewRedisLimiter(a.redisClient)
+	default:
+		return rateLimits, fmt.Errorf("unknown rate limit backend %q", a.config.RateLimitBackend)
+	}
+
+	var err error
+	if rateLimits.Login, err = ratelimit.ParsePolicy("login", a.config.RateLimitLogin); err != nil {
+		return rateLimits, err
+	}
+	if rateLimits.API, err = ratelimit.ParsePolicy("api", a.config.RateLimitAPI); err != nil {
+		return rateLimits, err
+	}
+	if rateLimits.Introspect, err = ratelimit.ParsePolicy("introspect", a.config.RateLimitIntrospect); err != nil {
+		return rateLimits, err
+	}
+	if rateLimits.TrustedProxies, err = ratelimit.ParseTrustedProxies(a.config.TrustedProxies); err != nil {
+		return rateLimits, err
+	}
+
+	return rateLimits, nil
+}
+
+// newSessionCookie builds the session cookie settings of the configuration
+func newSessionCookie(cfg *config.Config) (controller.SessionCookie, error) {
+	sameSites := map[string]http.SameSite{
+		"lax":    http.SameSiteLaxMode,
+		"strict": http.SameSiteStrictMode,
+		"none":   http.SameSiteNoneMode,
+	}
+	sameSite, ok := sameSites[strings.ToLower(cfg.SessionCookieSameSite)]
+	if !ok {
+		return controller.SessionCookie{}, fmt.Errorf("unknown session cookie same site %q", cfg.SessionCookieSameSite)
+	}
+	// browsers drop SameSite=None cookies which aren't secure
+	if sameSite == http.SameSiteNoneMode && !cfg.SessionCookieSecure {
+		return controller.SessionCookie{}, fmt.Errorf("session cookie with same site none must be secure")
+	}
+
+	return controller.SessionCookie{
+		Name:     cfg.SessionCookieName,
+		Domain:   cfg.SessionCookieDomain,
+		Secure:   cfg.SessionCookieSecure,
+		SameSite: sameSite,
+	}, nil
+}
@@ -0,0 +1,39 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/mocks"
+	"home24-technical-test/internal/user/model"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSeedDevelopmentUser(t *testing.T) {
+	// the storages may wrap the not found error
+	storage := &mocks.Storage{}
+	storage.On("FindByEmail", mock.Anything, "user@home24.com").Return(nil, fmt.Errorf("find user: %w", user.ErrNotFound))
+	storage.On("Insert", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+
+	if err := seedDevelopmentUser(storage); err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	storage.AssertCalled(t, "Insert", mock.Anything, mock.AnythingOfType("*model.User"))
+
+	// an existing user is kept
+	storage = &mocks.Storage{}
+	storage.On("FindByEmail", mock.Anything, "user@home24.com").Return(&model.User{ID: 1}, nil)
+	if err := seedDevelopmentUser(storage); err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	storage.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+
+	storage = &mocks.Storage{}
+	storage.On("FindByEmail", mock.Anything, "user@home24.com").Return(nil, errors.New("connection refused"))
+	if err := seedDevelopmentUser(storage); err == nil {
+		t.Error("expected the storage failure to be reported")
+	}
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/data"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var (
+	errAccessDenied = apperror.New(apperror.CodePermissionDenied, "access denied")
+	errUnauthorized = apperror.New(apperror.CodeUnauthenticated, "unauthorized")
+)
+
+// publicMethods don't need a session
+var publicMethods = map[string]bool{
+	"/home24.user.v1.UserService/Login":           true,
+	"/home24.user.v1.UserService/ValidateSession": true,
+}
+
+// authorizedOnly is the gRPC counterpart of the http authorizedOnly middleware,
+// it puts the session id & the user id of the "authorization: session {token}" metadata into the context
+func authorizedOnly(getUserAdapter userAdapter.GetUserAdapter, getLoginSessionAdapter userAdapter.GetLoginSessionAdapter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		token := getSessionToken(ctx)
+		if token == "" {
+			return nil, toStatus(ctx, path.Base(info.FullMethod), errAccessDenied)
+		}
+
+		userSession, err := validateSession(ctx, getUserAdapter, getLoginSessionAdapter, token)
+		if err != nil {
+			return nil, toStatus(ctx, path.Base(info.FullMethod), err)
+		}
+
+		ctx = context.WithValue(ctx, appcontext.KeySessionID, token)
+		ctx = context.WithValue(ctx, appcontext.KeyUserID, userSession.UserID())
+		ctx = context.WithValue(ctx, appcontext.KeyRoles, userSession.Roles())
+
+		return handler(ctx, req)
+	}
+}
+
+// requireRole rejects the sessions not granted the role, the gRPC counterpart of the http requireRole middleware
+func requireRole(ctx context.Context, role string) error {
+	if !appcontext.HasRole(ctx, role) {
+		return errAccessDenied
+	}
+	return nil
+}
+
+// selfOrRole rejects the sessions of another user than userID unless they are granted the role
+func selfOrRole(ctx context.Context, userID int, role string) error {
+	if userID != appcontext.UserID(ctx) && !appcontext.HasRole(ctx, role) {
+		return errAccessDenied
+	}
+	return nil
+}
+
+// validateSession gets the login session of the token, errUnauthorized when the session or its user doesn't exist anymore
+func validateSession(ctx context.Context, getUserAdapter userAdapter.GetUserAdapter, getLoginSessionAdapter userAdapter.GetLoginSessionAdapter, token string) (*model.Session, error) {
+	userSession, err := getLoginSessionAdapter.Execute(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if userSession == nil {
+		return nil, errUnauthorized
+	}
+
+	_, err = getUserAdapter.Execute(ctx, userSession.UserID())
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, errUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return userSession, nil
+}
+
+func getSessionToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+
+	token := strings.TrimSpace(values[0])
+	if !strings.HasPrefix(token, "session ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(token, "session "))
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/service/mocks"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/data"
+
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newTestInterceptor is the auth interceptor over a mocked service knowing the session "valid" of user 1,
+// "expired" is gone like the expired sessions & "orphan" belongs to a deleted user
+func newTestInterceptor() grpc.UnaryServerInterceptor {
+	svc := &mocks.ServiceInterface{}
+	svc.On("GetLoginSession", mock.Anything, "valid").Return(&model.Session{
+		ID:   "valid",
+		Type: "login",
+		Info: map[string]interface{}{"UserID": float64(1), "Roles": []interface{}{"user"}},
+	}, nil)
+	svc.On("GetLoginSession", mock.Anything, "orphan").Return(&model.Session{
+		ID:   "orphan",
+		Type: "login",
+		Info: map[string]interface{}{"UserID": float64(2)},
+	}, nil)
+	svc.On("GetLoginSession", mock.Anything, "failing").Return(nil, errors.New("session storage is down"))
+	svc.On("GetLoginSession", mock.Anything, mock.Anything).Return(nil, nil)
+	svc.On("GetUser", mock.Anything, 1).Return(&model.User{ID: 1}, nil)
+	svc.On("GetUser", mock.Anything, 2).Return(nil, data.ErrNotFound)
+
+	adapters := userAdapter.NewAdapters(svc)
+	return authorizedOnly(adapters.GetUser, adapters.GetLoginSession)
+}
+
+// authorizationContext is the context of a call with the authorization metadata, none when empty
+func authorizationContext(authorization string) context.Context {
+	ctx := context.Background()
+	if authorization == "" {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+}
+
+func TestAuthorizedOnly(t *testing.T) {
+	interceptor := newTestInterceptor()
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		code          codes.Code
+	}{
+		{"missing token", "GetUser", "", codes.PermissionDenied},
+		{"malformed token", "GetUser", "Bearer valid", codes.PermissionDenied},
+		{"expired token", "GetUser", "session expired", codes.Unauthenticated},
+		{"deleted user", "GetUser", "session orphan", codes.Unauthenticated},
+		{"session storage failure", "GetUser", "session failing", codes.Internal},
+		{"valid token", "GetUser", "session valid", codes.OK},
+		{"public method", "Login", "", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled context.Context
+			_, err := interceptor(authorizationContext(tt.authorization), nil,
+				&grpc.UnaryServerInfo{FullMethod: "/home24.user.v1.UserService/" + tt.method},
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					handled = ctx
+					return nil, nil
+				})
+
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("expected %v, got %v", tt.code, err)
+			}
+			if (handled != nil) != (tt.code == codes.OK) {
+				t.Errorf("expected the handler to be called only when authorized")
+			}
+		})
+	}
+}
+
+func TestAuthorizedOnly_Context(t *testing.T) {
+	interceptor := newTestInterceptor()
+
+	var handled context.Context
+	_, err := interceptor(authorizationContext("session  valid "), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/home24.user.v1.UserService/GetUser"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			handled = ctx
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("expected the call to be authorized, got %v", err)
+	}
+
+	if appcontext.SessionID(handled) != "valid" || appcontext.UserID(handled) != 1 || !appcontext.HasRole(handled, "user") {
+		t.Errorf("expected the session, the user & the roles in the context, got %q, %d, %v",
+			appcontext.SessionID(handled), appcontext.UserID(handled), appcontext.Roles(handled))
+	}
+}
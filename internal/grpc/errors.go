@@ -0,0 +1,59 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"home24-technical-test/pkg/apperror"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var grpcCodes = map[apperror.Code]codes.Code{
+	apperror.CodeInvalidArgument:      codes.InvalidArgument,
+	apperror.CodeUnauthenticated:      codes.Unauthenticated,
+	apperror.CodePermissionDenied:     codes.PermissionDenied,
+	apperror.CodeNotFound:             codes.NotFound,
+	apperror.CodeConflict:             codes.AlreadyExists,
+	apperror.CodePreconditionFailed:   codes.FailedPrecondition,
+	apperror.CodePayloadTooLarge:      codes.InvalidArgument,
+	apperror.CodeValidationFailed:     codes.InvalidArgument,
+	apperror.CodeUnsupportedMediaType: codes.InvalidArgument,
+	apperror.CodePreconditionRequired: codes.FailedPrecondition,
+	apperror.CodeTooManyRequests:      codes.ResourceExhausted,
+	apperror.CodeInternal:             codes.Internal,
+	apperror.CodeUnavailable:          codes.Unavailable,
+}
+
+// toStatus maps the error to its gRPC status, it is the gRPC counterpart of response.Error
+func toStatus(ctx context.Context, method string, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return status.Error(codes.Canceled, err.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	log.Printf("Error: [%s] %v\n", method, err)
+
+	code, ok := grpcCodes[apperror.CodeOf(err)]
+	if !ok {
+		code = codes.Internal
+	}
+	// the cause of server errors is only logged, it may leak internals
+	if code == codes.Internal {
+		return status.Error(code, "internal error")
+	}
+
+	message := err.Error()
+	for _, field := range apperror.FieldsOf(err) {
+		message += fmt.Sprintf("; %s %s", field.Field, field.Message)
+	}
+	return status.Error(code, message)
+}
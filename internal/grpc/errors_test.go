@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/http/ratelimit"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{"invalid argument", user.ErrInvalidCredentials, codes.InvalidArgument, user.ErrInvalidCredentials.Error()},
+		{"unauthenticated", errUnauthorized, codes.Unauthenticated, "unauthorized"},
+		{"permission denied", errAccessDenied, codes.PermissionDenied, "access denied"},
+		{"not found", data.ErrNotFound, codes.NotFound, data.ErrNotFound.Error()},
+		{"conflict", user.ErrEmailAlreadyExists, codes.AlreadyExists, user.ErrEmailAlreadyExists.Error()},
+		{"precondition failed", user.ErrVersionConflict, codes.FailedPrecondition, user.ErrVersionConflict.Error()},
+		{"too many requests", ratelimit.ErrRateLimited, codes.ResourceExhausted, ratelimit.ErrRateLimited.Error()},
+		{"wrapped", fmt.Errorf("login: %w", user.ErrWrongPassword), codes.InvalidArgument, "login: wrong password"},
+		{"validation failed", apperror.NewValidation([]apperror.FieldError{{Field: "email", Message: "must be an email"}}),
+			codes.InvalidArgument, "validation failed; email must be an email"},
+		{"unknown error", errors.New("connection refused"), codes.Internal, "internal error"},
+		{"internal", apperror.New(apperror.CodeInternal, "disk full"), codes.Internal, "internal error"},
+		{"canceled", context.Canceled, codes.Canceled, context.Canceled.Error()},
+		{"deadline exceeded", fmt.Errorf("query: %w", context.DeadlineExceeded), codes.DeadlineExceeded, "query: context deadline exceeded"},
+		{"status", status.Error(codes.Unavailable, "draining"), codes.Unavailable, "draining"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := status.FromError(toStatus(context.Background(), "Test", tt.err))
+			if !ok {
+				t.Fatalf("expected a status error")
+			}
+			if st.Code() != tt.code || st.Message() != tt.message {
+				t.Errorf("expected %v %q, got %v %q", tt.code, tt.message, st.Code(), st.Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"path"
+	"strconv"
+
+	"home24-technical-test/pkg/http/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// RateLimits are the rate limit policies of the methods, a nil Limiter disables rate limiting
+type RateLimits struct {
+	Limiter ratelimit.Limiter
+	// Login limits the login attempts per ip, the buckets are shared with the http login of the same policy
+	Login ratelimit.Policy
+}
+
+// rateLimit is the gRPC counterpart of the http rate limit middleware, it limits the calls of the methods
+// with a policy by peer ip. The limiter failing lets the calls through
+func rateLimit(rateLimits RateLimits) grpc.UnaryServerInterceptor {
+	policies := map[string]ratelimit.Policy{
+		"/home24.user.v1.UserService/Login": rateLimits.Login,
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		policy, ok := policies[info.FullMethod]
+		if !ok || rateLimits.Limiter == nil {
+			return handler(ctx, req)
+		}
+
+		key := peerIP(ctx)
+		if key == "" {
+			return handler(ctx, req)
+		}
+
+		result, err := rateLimits.Limiter.Allow(ctx, policy.Name+":ip:"+key, policy)
+		if err != nil {
+			log.Printf("Error: [%s] rate limiter: %v\n", policy.Name, err)
+			return handler(ctx, req)
+		}
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+			return nil, toStatus(ctx, path.Base(info.FullMethod), ratelimit.ErrRateLimited)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// peerIP returns the ip of the socket peer, empty when unknown
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
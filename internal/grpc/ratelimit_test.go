@@ -0,0 +1,70 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"home24-technical-test/pkg/http/ratelimit"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// failingLimiter is a limiter whose backend is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, policy ratelimit.Policy) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("limiter is down")
+}
+
+// peerContext is the context of a call from the ip
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4321}})
+}
+
+func TestRateLimit(t *testing.T) {
+	interceptor := rateLimit(RateLimits{
+		Limiter: ratelimit.NewMemoryLimiter(),
+		Login:   ratelimit.Policy{Name: "login", Requests: 1, Per: time.Minute, Burst: 1},
+	})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	call := func(ctx context.Context, method string) error {
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/home24.user.v1.UserService/" + method}, handler)
+		return err
+	}
+
+	if err := call(peerContext("203.0.113.7"), "Login"); err != nil {
+		t.Fatalf("expected the first login to pass, got %v", err)
+	}
+	if err := call(peerContext("203.0.113.7"), "Login"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected the second login to be rate limited, got %v", err)
+	}
+	if err := call(peerContext("198.51.100.1"), "Login"); err != nil {
+		t.Errorf("expected the login of another peer to pass, got %v", err)
+	}
+	if err := call(peerContext("203.0.113.7"), "GetUser"); err != nil {
+		t.Errorf("expected the methods without policy to pass, got %v", err)
+	}
+}
+
+func TestRateLimit_LimiterFailure(t *testing.T) {
+	interceptor := rateLimit(RateLimits{
+		Limiter: failingLimiter{},
+		Login:   ratelimit.Policy{Name: "login", Requests: 1, Per: time.Minute, Burst: 1},
+	})
+
+	resp, err := interceptor(peerContext("203.0.113.7"), nil, &grpc.UnaryServerInfo{FullMethod: "/home24.user.v1.UserService/Login"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "ok", nil
+		})
+	if err != nil || resp != "ok" {
+		t.Errorf("expected the login to pass when the limiter fails, got %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,254 @@
+package grpc
+
+import (
+	"context"
+	"time"
+
+	"home24-technical-test/internal/user"
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	userPublic "home24-technical-test/internal/user/public"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/grpc/userpb"
+	"home24-technical-test/pkg/validation"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserServer implements the gRPC user service on top of the user adapters
+type UserServer struct {
+	userpb.UnimplementedUserServiceServer
+
+	getUserAdapter         userAdapter.GetUserAdapter
+	getLoginSessionAdapter userAdapter.GetLoginSessionAdapter
+	loginAdapter           userAdapter.LoginAdapter
+	logoutAdapter          userAdapter.LogoutAdapter
+	changePasswordAdapter  userAdapter.ChangePasswordAdapter
+	listUsersAdapter       userAdapter.ListUsersAdapter
+	dataManager            *data.Manager
+}
+
+// Login logs the user in
+func (s *UserServer) Login(ctx context.Context, req *userpb.LoginRequest) (*userpb.LoginResponse, error) {
+	params := userPublic.LoginParams{
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if err := validation.Validate(&params); err != nil {
+		return nil, toStatus(ctx, "Login", err)
+	}
+
+	var sess *userPublic.LoginResponse
+	err := s.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		var err error
+		sess, err = s.loginAdapter.Execute(tctx, &params)
+		return err
+	})
+	if err != nil {
+		return nil, toStatus(ctx, "Login", err)
+	}
+
+	return &userpb.LoginResponse{
+		SessionId: sess.SessionID,
+		User:      newUser(sess.User),
+	}, nil
+}
+
+// Logout logs the current session out
+func (s *UserServer) Logout(ctx context.Context, req *userpb.LogoutRequest) (*userpb.LogoutResponse, error) {
+	token := appcontext.SessionID(ctx)
+
+	err := s.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		return s.logoutAdapter.Execute(tctx, token)
+	})
+	if err != nil {
+		return nil, toStatus(ctx, "Logout", err)
+	}
+
+	return &userpb.LogoutResponse{}, nil
+}
+
+// ValidateSession gets the login session of the given session id
+func (s *UserServer) ValidateSession(ctx context.Context, req *userpb.ValidateSessionRequest) (*userpb.Session, error) {
+	if req.GetSessionId() == "" {
+		return nil, toStatus(ctx, "ValidateSession", errUnauthorized)
+	}
+
+	session, err := validateSession(ctx, s.getUserAdapter, s.getLoginSessionAdapter, req.GetSessionId())
+	if err != nil {
+		return nil, toStatus(ctx, "ValidateSession", err)
+	}
+
+	return newSession(session), nil
+}
+
+// GetUser gets a user by its id, users get themselves unless admin
+func (s *UserServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	if err := selfOrRole(ctx, int(req.GetId()), user.AdminRole); err != nil {
+		return nil, toStatus(ctx, "GetUser", err)
+	}
+
+	u, err := s.getUserAdapter.Execute(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, toStatus(ctx, "GetUser", err)
+	}
+
+	return newUser(u), nil
+}
+
+// ListUsers lists a page of the users, admin only
+func (s *UserServer) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	if err := requireRole(ctx, user.AdminRole); err != nil {
+		return nil, toStatus(ctx, "ListUsers", err)
+	}
+
+	params := &userPublic.FindAllUsersParams{
+		Search:    req.GetSearch(),
+		Email:     req.GetEmail(),
+		Name:      req.GetName(),
+		SortBy:    req.GetSortBy(),
+		SortOrder: req.GetSortOrder(),
+		Limit:     int(req.GetLimit()),
+		Cursor:    req.GetCursor(),
+	}
+	if req.GetCreatedFrom() != nil {
+		createdFrom := req.GetCreatedFrom().AsTime()
+		params.CreatedFrom = &createdFrom
+	}
+	if req.GetCreatedTo() != nil {
+		createdTo := req.GetCreatedTo().AsTime()
+		params.CreatedTo = &createdTo
+	}
+
+	page, err := s.listUsersAdapter.Execute(ctx, params)
+	if err != nil {
+		return nil, toStatus(ctx, "ListUsers", err)
+	}
+
+	users := make([]*userpb.User, 0, len(page.Users))
+	for _, u := range page.Users {
+		users = append(users, newUser(u))
+	}
+
+	return &userpb.ListUsersResponse{
+		Users:      users,
+		Total:      int64(page.Total),
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}, nil
+}
+
+// ChangePassword changes the password of the current user
+func (s *UserServer) ChangePassword(ctx context.Context, req *userpb.ChangePasswordRequest) (*userpb.ChangePasswordResponse, error) {
+	params := userPublic.ChangePasswordParams{
+		OldPassword: req.GetOldPassword(),
+		NewPassword: req.GetNewPassword(),
+	}
+	if err := validation.Validate(&params); err != nil {
+		return nil, toStatus(ctx, "ChangePassword", err)
+	}
+
+	userID := appcontext.UserID(ctx)
+	err := s.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		return s.changePasswordAdapter.Execute(tctx, userID, params.OldPassword, params.NewPassword)
+	})
+	if err != nil {
+		return nil, toStatus(ctx, "ChangePassword", err)
+	}
+
+	return &userpb.ChangePasswordResponse{}, nil
+}
+
+func newUser(u *model.User) *userpb.User {
+	if u == nil {
+		return nil
+	}
+
+	pb := &userpb.User{
+		Id:                    int64(u.ID),
+		Name:                  u.Name,
+		Email:                 u.Email,
+		Address:               u.Address,
+		PhoneNumber:           u.PhoneNumber,
+		Locale:                u.Locale,
+		Timezone:              u.Timezone,
+		MarketingEmailConsent: u.MarketingEmailConsent,
+		MarketingSmsConsent:   u.MarketingSMSConsent,
+		CustomAttributes:      newStruct(u.CustomAttributes),
+	}
+	if !u.CreatedAt.IsZero() {
+		pb.CreatedAt = timestamppb.New(u.CreatedAt)
+	}
+	if u.Avatar != "" {
+		pb.AvatarUrl = userPublic.AvatarURL(u.ID)
+	}
+
+	return pb
+}
+
+func newSession(session *model.Session) *userpb.Session {
+	pb := &userpb.Session{
+		Id:     session.ID,
+		Type:   session.Type,
+		UserId: int64(session.UserID()),
+		User:   newUser(session.User),
+	}
+	if !session.ExpiredAt.IsZero() {
+		pb.ExpiredAt = timestamppb.New(session.ExpiredAt)
+	}
+	if pb.User != nil {
+		pb.User.Id = pb.UserId
+	}
+
+	return pb
+}
+
+// newStruct converts the custom attributes, attributes that can't be represented are dropped
+func newStruct(attributes model.Attributes) *structpb.Struct {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]*structpb.Value, len(attributes))
+	for key, value := range attributes {
+		v, err := structpb.NewValue(value)
+		if err != nil {
+			continue
+		}
+		fields[key] = v
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// NewServer creates the gRPC server of the user service with the rate limit & the auth interceptors,
+// opts are added to the server options (e.g. the transport credentials)
+func NewServer(
+	adapters userAdapter.Adapters,
+	dataManager *data.Manager,
+	rateLimits RateLimits,
+	opts ...grpc.ServerOption,
+) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			rateLimit(rateLimits),
+			authorizedOnly(adapters.GetUser, adapters.GetLoginSession),
+		),
+		grpc.ConnectionTimeout(60 * time.Second),
+	}, opts...)
+	s := grpc.NewServer(opts...)
+
+	userpb.RegisterUserServiceServer(s, &UserServer{
+		getUserAdapter:         adapters.GetUser,
+		getLoginSessionAdapter: adapters.GetLoginSession,
+		loginAdapter:           adapters.Login,
+		logoutAdapter:          adapters.Logout,
+		changePasswordAdapter:  adapters.ChangePassword,
+		listUsersAdapter:       adapters.ListUsers,
+		dataManager:            dataManager,
+	})
+
+	return s
+}
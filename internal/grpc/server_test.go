@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"home24-technical-test/internal/user"
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service/mocks"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/grpc/userpb"
+
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sessionContext is the context the auth interceptor builds for a session of the user & the roles
+func sessionContext(userID int, roles ...string) context.Context {
+	ctx := context.WithValue(context.Background(), appcontext.KeyUserID, userID)
+	return context.WithValue(ctx, appcontext.KeyRoles, roles)
+}
+
+func newTestUserServer() *UserServer {
+	svc := &mocks.ServiceInterface{}
+	svc.On("GetUser", mock.Anything, 1).Return(&model.User{ID: 1, Email: "user@home24.com"}, nil)
+	svc.On("GetUser", mock.Anything, 2).Return(&model.User{ID: 2, Email: "other@home24.com"}, nil)
+	svc.On("ListUsers", mock.Anything, mock.Anything).Return(&public.UsersPage{Users: []*model.User{{ID: 1}, {ID: 2}}, Total: 2}, nil)
+
+	adapters := userAdapter.NewAdapters(svc)
+	return &UserServer{
+		getUserAdapter:   adapters.GetUser,
+		listUsersAdapter: adapters.ListUsers,
+	}
+}
+
+func TestUserServer_GetUser(t *testing.T) {
+	s := newTestUserServer()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userID int64
+		code   codes.Code
+	}{
+		{"self", sessionContext(1, "user"), 1, codes.OK},
+		{"another user", sessionContext(1, "user"), 2, codes.PermissionDenied},
+		{"admin", sessionContext(1, "user", user.AdminRole), 2, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := s.GetUser(tt.ctx, &userpb.GetUserRequest{Id: tt.userID})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("expected %v, got %v", tt.code, err)
+			}
+			if tt.code == codes.OK && u.GetId() != tt.userID {
+				t.Errorf("expected user %d, got %+v", tt.userID, u)
+			}
+		})
+	}
+}
+
+func TestUserServer_ListUsers(t *testing.T) {
+	s := newTestUserServer()
+
+	if _, err := s.ListUsers(sessionContext(1, "user"), &userpb.ListUsersRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected a user session to be denied, got %v", err)
+	}
+
+	page, err := s.ListUsers(sessionContext(1, "user", user.AdminRole), &userpb.ListUsersRequest{})
+	if err != nil {
+		t.Fatalf("expected an admin session to list the users, got %v", err)
+	}
+	if page.GetTotal() != 2 || len(page.GetUsers()) != 2 {
+		t.Errorf("expected the users page, got %+v", page)
+	}
+}
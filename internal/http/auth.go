@@ -2,49 +2,131 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/data"
 	"home24-technical-test/pkg/http/response"
 
 	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+
+	"github.com/go-chi/chi"
+)
+
+var (
+	errAccessDenied       = apperror.New(apperror.CodePermissionDenied, "access denied")
+	errUnauthorized       = apperror.New(apperror.CodeUnauthenticated, "unauthorized")
+	errInvalidCredentials = apperror.New(apperror.CodeUnauthenticated, "invalid client credentials")
+	errInvalidCSRFToken   = apperror.New(apperror.CodePermissionDenied, "invalid csrf token")
 )
 
+// ClientCredentials are the credentials of the services allowed to introspect tokens
+type ClientCredentials struct {
+	// Clients maps the client ids to their secrets, sent with basic auth
+	Clients map[string]string
+	// APIKeys are sent in the X-API-Key header
+	APIKeys []string
+}
+
+// authenticate tells whether the request carries valid client credentials or a valid api key
+func (cc ClientCredentials) authenticate(r *http.Request) bool {
+	if clientID, secret, ok := r.BasicAuth(); ok {
+		expected, found := cc.Clients[clientID]
+		// compare anyway so unknown clients take as long as known ones
+		match := subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+		return found && match
+	}
+
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		valid := false
+		for _, key := range cc.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+				valid = true
+			}
+		}
+		return valid
+	}
+
+	return false
+}
+
+// clientsOnly only lets the requests with valid client credentials or a known client certificate through
+func (hs *Server) clientsOnly(credentials ClientCredentials) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if appcontext.ServiceIdentity(r.Context()) == "" && !credentials.authenticate(r) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+				response.Error(w, r, errInvalidCredentials)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// authorizedOnly puts the session id & the user id of the request into the context.
+// The session is taken from the Authorization header, or from the session cookie for the browsers.
+// Cookie sessions must send the csrf token of the session in the X-CSRF-Token header of the state changing requests
 func (hs *Server) authorizedOnly(getUserAdapter userAdapter.GetUserAdapter, getLoginSessionAdapter userAdapter.GetLoginSessionAdapter) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			var userID int
+			var roles []string
 
 			ctx := r.Context()
 			session := getSessionToken(r)
+			fromCookie := false
+			if session == "" {
+				session = getSessionCookie(r, hs.sessionCookie.Name)
+				fromCookie = true
+			}
+
 			if session == "" {
-				response.Error(w, "Forbidden", http.StatusForbidden, fmt.Errorf("Access denied"))
+				response.Error(w, r, errAccessDenied)
 				return
 			} else {
 				userSession, err := getLoginSessionAdapter.Execute(ctx, session)
 				if err != nil {
-					response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
+					response.Error(w, r, err)
 					return
 				}
 				if userSession == nil {
-					response.Error(w, "Unauthorized", http.StatusUnauthorized, fmt.Errorf("Unauthorized"))
+					response.Error(w, r, errUnauthorized)
+					return
+				}
+
+				if fromCookie && !isSafeMethod(r.Method) && !validCSRFToken(r, userSession) {
+					response.Error(w, r, errInvalidCSRFToken)
 					return
 				}
 
-				userData, err := getUserAdapter.Execute(ctx, int(userSession.Info["UserID"].(float64)))
+				userData, err := getUserAdapter.Execute(ctx, userSession.UserID())
+				if errors.Is(err, data.ErrNotFound) {
+					response.Error(w, r, errUnauthorized)
+					return
+				}
 				if err != nil {
-					response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
+					response.Error(w, r, err)
 					return
 				}
 				userID = userData.ID
+				roles = userSession.Roles()
 			}
 			ctx = context.WithValue(ctx, appcontext.KeySessionID, session)
 
 			if userID != 0 {
 				ctx = context.WithValue(ctx, appcontext.KeyUserID, userID)
 			}
+			ctx = context.WithValue(ctx, appcontext.KeyRoles, roles)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 
@@ -52,6 +134,68 @@ func (hs *Server) authorizedOnly(getUserAdapter userAdapter.GetUserAdapter, getL
 	}
 }
 
+// requireRole only lets the sessions granted the role through, it goes after authorizedOnly
+func (hs *Server) requireRole(role string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if !appcontext.HasRole(r.Context(), role) {
+				response.Error(w, r, errAccessDenied)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// selfOrRole only lets the sessions of the user of the {id} route param, or the sessions granted the role, through.
+// It goes after authorizedOnly
+func (hs *Server) selfOrRole(role string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+			if (err != nil || userID != appcontext.UserID(ctx)) && !appcontext.HasRole(ctx, role) {
+				response.Error(w, r, errAccessDenied)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func getSessionCookie(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// isSafeMethod tells whether the method doesn't change any state, those requests don't need a csrf token
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// validCSRFToken tells whether the X-CSRF-Token header matches the csrf token of the session
+func validCSRFToken(r *http.Request, session *model.Session) bool {
+	expected := session.CSRFToken()
+	token := r.Header.Get("X-CSRF-Token")
+	if expected == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
 func getSessionToken(r *http.Request) string {
 	token := r.Header.Get("Authorization")
 	splitToken := strings.Split(token, "session")
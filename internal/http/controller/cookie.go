@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+)
+
+// SessionCookie configures the session cookie set on login for the browsers
+type SessionCookie struct {
+	Name   string
+	Domain string
+	// Secure only sends the cookie over https, it can only be disabled for local development
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// cookie builds the session cookie of the session id, it expires with the session
+func (sc SessionCookie) cookie(sessionID string, expiredAt time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     sc.Name,
+		Value:    sessionID,
+		Path:     "/",
+		Domain:   sc.Domain,
+		Expires:  expiredAt,
+		MaxAge:   int(time.Until(expiredAt).Seconds()),
+		Secure:   sc.Secure,
+		HttpOnly: true,
+		SameSite: sc.SameSite,
+	}
+}
+
+// expiredCookie builds a cookie removing the session cookie from the browser
+func (sc SessionCookie) expiredCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     sc.Name,
+		Value:    "",
+		Path:     "/",
+		Domain:   sc.Domain,
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		Secure:   sc.Secure,
+		HttpOnly: true,
+		SameSite: sc.SameSite,
+	}
+}
@@ -2,36 +2,51 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"home24-technical-test/internal/user"
 	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
 	userPublic "home24-technical-test/internal/user/public"
 	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
 	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/http/request"
 	"home24-technical-test/pkg/http/response"
+
+	"github.com/go-chi/chi"
 )
 
 // UserController represents the user controller
 type UserController struct {
-	getLoginSessionAdapter userAdapter.GetLoginSessionAdapter
-	loginAdapter           userAdapter.LoginAdapter
-	logoutAdapter          userAdapter.LogoutAdapter
-	changePasswordAdapter  userAdapter.ChangePasswordAdapter
-	dataManager            *data.Manager
+	getUserAdapter          userAdapter.GetUserAdapter
+	updateUserAdapter       userAdapter.UpdateUserAdapter
+	uploadAvatarAdapter     userAdapter.UploadAvatarAdapter
+	getAvatarAdapter        userAdapter.GetAvatarAdapter
+	getLoginSessionAdapter  userAdapter.GetLoginSessionAdapter
+	loginAdapter            userAdapter.LoginAdapter
+	logoutAdapter           userAdapter.LogoutAdapter
+	changePasswordAdapter   userAdapter.ChangePasswordAdapter
+	listUsersAdapter        userAdapter.ListUsersAdapter
+	listDeletedUsersAdapter userAdapter.ListDeletedUsersAdapter
+	restoreUserAdapter      userAdapter.RestoreUserAdapter
+	introspectTokenAdapter  userAdapter.IntrospectTokenAdapter
+	dataManager             *data.Manager
+	sessionCookie           SessionCookie
 }
 
 // Login POST /login
 func (uc *UserController) Login(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-
 	var params userPublic.LoginParams
-	err := decoder.Decode(&params)
+	err := request.DecodeJSON(w, r, &params)
 	if err != nil {
-		response.Error(w, "Bad Request", http.StatusBadRequest, err)
+		response.Error(w, r, err)
 		return
 	}
 
@@ -39,23 +54,15 @@ func (uc *UserController) Login(w http.ResponseWriter, r *http.Request) {
 	var errLogin error
 	var sess *userPublic.LoginResponse
 	err = uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
-		sess, errLogin = uc.loginAdapter.Execute(r.Context(), &params)
+		sess, errLogin = uc.loginAdapter.Execute(tctx, &params)
 		return errLogin
 	})
 	if err != nil {
-		if err == user.ErrWrongPassword || err == data.ErrNotFound {
-			response.Error(w, "Email or password is wrong", http.StatusBadRequest, err)
-		} else {
-			fmt.Printf("Error: %v", err)
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
-		}
+		response.Error(w, r, err)
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:  "sessionId",
-		Value: sess.SessionID,
-	})
+	http.SetCookie(w, uc.sessionCookie.cookie(sess.SessionID, sess.ExpiredAt))
 
 	response.JSON(w, http.StatusOK, sess)
 }
@@ -66,11 +73,7 @@ func (uc *UserController) GetLoginSession(w http.ResponseWriter, r *http.Request
 
 	sess, err := uc.getLoginSessionAdapter.Execute(r.Context(), token)
 	if err != nil {
-		if err == user.ErrWrongPassword || err == data.ErrNotFound {
-			response.Error(w, "Email or password is wrong", http.StatusBadRequest, err)
-		} else {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
-		}
+		response.Error(w, r, err)
 		return
 	}
 
@@ -79,27 +82,21 @@ func (uc *UserController) GetLoginSession(w http.ResponseWriter, r *http.Request
 
 // ChangePassword PUT /users/password
 func (uc *UserController) ChangePassword(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-
 	var params userPublic.ChangePasswordParams
-	err := decoder.Decode(&params)
+	err := request.DecodeJSON(w, r, &params)
 	if err != nil {
-		response.Error(w, "Bad Request", http.StatusBadRequest, err)
+		response.Error(w, r, err)
 		return
 	}
 
 	ctx := r.Context()
 	userID := appcontext.UserID(ctx)
 	err = uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
-		err = uc.changePasswordAdapter.Execute(ctx, userID, params.OldPassword, params.NewPassword)
+		err = uc.changePasswordAdapter.Execute(tctx, userID, params.OldPassword, params.NewPassword)
 		return err
 	})
 	if err != nil {
-		if err == user.ErrWrongPassword {
-			response.Error(w, "Wrong old password", http.StatusBadRequest, err)
-		} else {
-			response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
-		}
+		response.Error(w, r, err)
 		return
 	}
 
@@ -113,36 +110,366 @@ func (uc *UserController) Logout(w http.ResponseWriter, r *http.Request) {
 	// log it out!
 	loginToken, ok := ctx.Value(appcontext.KeySessionID).(string)
 	if !ok {
-		err := errors.New("failed to get user id from request context")
-		response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
+		err := errors.New("failed to get session id from request context")
+		response.Error(w, r, err)
 		return
 	}
 
 	err := uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
-		err := uc.logoutAdapter.Execute(ctx, loginToken)
+		err := uc.logoutAdapter.Execute(tctx, loginToken)
 		return err
 	})
 	if err != nil {
-		response.Error(w, "Internal Server Error", http.StatusInternalServerError, err)
+		response.Error(w, r, err)
 		return
 	}
 
+	http.SetCookie(w, uc.sessionCookie.expiredCookie())
+
 	response.JSON(w, http.StatusNoContent, "")
 }
 
+// ListUsers GET /v1/users
+func (uc *UserController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := parseFindAllUsersParams(r)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	page, err := uc.listUsersAdapter.Execute(r.Context(), params)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newListUsersResponse(page))
+}
+
+// ListDeletedUsers GET /v1/users/deleted
+func (uc *UserController) ListDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	params, err := parseFindAllUsersParams(r)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	page, err := uc.listDeletedUsersAdapter.Execute(r.Context(), params)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, newListUsersResponse(page))
+}
+
+func parseFindAllUsersParams(r *http.Request) (*userPublic.FindAllUsersParams, error) {
+	query := r.URL.Query()
+
+	params := &userPublic.FindAllUsersParams{
+		Search:    query.Get("search"),
+		Email:     query.Get("email"),
+		Name:      query.Get("name"),
+		SortBy:    query.Get("sortBy"),
+		SortOrder: query.Get("sortOrder"),
+		Cursor:    query.Get("cursor"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, apperror.Wrap(err, apperror.CodeInvalidArgument, "invalid limit")
+		}
+		params.Limit = l
+	}
+	if createdFrom := query.Get("createdFrom"); createdFrom != "" {
+		t, err := time.Parse(time.RFC3339, createdFrom)
+		if err != nil {
+			return nil, apperror.Wrap(err, apperror.CodeInvalidArgument, "invalid createdFrom")
+		}
+		params.CreatedFrom = &t
+	}
+	if createdTo := query.Get("createdTo"); createdTo != "" {
+		t, err := time.Parse(time.RFC3339, createdTo)
+		if err != nil {
+			return nil, apperror.Wrap(err, apperror.CodeInvalidArgument, "invalid createdTo")
+		}
+		params.CreatedTo = &t
+	}
+
+	return params, nil
+}
+
+func newListUsersResponse(page *userPublic.UsersPage) *userPublic.ListUsersResponse {
+	users := make([]*userPublic.UserResponse, 0, len(page.Users))
+	for _, u := range page.Users {
+		users = append(users, newUserResponse(u))
+	}
+
+	return &userPublic.ListUsersResponse{
+		Users:      users,
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+}
+
+func newUserResponse(u *model.User) *userPublic.UserResponse {
+	return &userPublic.UserResponse{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Address:   u.Address,
+		CreatedAt: u.CreatedAt,
+		DeletedAt: u.DeletedAt,
+		DeletedBy: u.DeletedBy,
+
+		PhoneNumber:           u.PhoneNumber,
+		Locale:                u.Locale,
+		Timezone:              u.Timezone,
+		MarketingEmailConsent: u.MarketingEmailConsent,
+		MarketingSMSConsent:   u.MarketingSMSConsent,
+		AvatarURL:             avatarURL(u),
+		CustomAttributes:      u.CustomAttributes,
+	}
+}
+
+func avatarURL(u *model.User) string {
+	if u.Avatar == "" {
+		return ""
+	}
+	return userPublic.AvatarURL(u.ID)
+}
+
+// GetUser GET /v1/users/{id}
+func (uc *UserController) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	u, err := uc.getUserAdapter.Execute(r.Context(), userID)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(u.Version))
+	response.JSON(w, http.StatusOK, newUserResponse(u))
+}
+
+// UpdateUser PUT /v1/users/{id}
+func (uc *UserController) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		response.Error(w, r, errIfMatchRequired)
+		return
+	}
+	version, err := parseETag(ifMatch)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	var params userPublic.UpdateUserParams
+	err = request.DecodeJSON(w, r, &params)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	params.ID = userID
+	params.Version = version
+
+	ctx := r.Context()
+	var updatedUser *model.User
+	err = uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		updatedUser, err = uc.updateUserAdapter.Execute(tctx, &params)
+		return err
+	})
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updatedUser.Version))
+	response.JSON(w, http.StatusOK, newUserResponse(updatedUser))
+}
+
+// maxAvatarRequestSize caps the upload request body, the configured avatar size is checked by the service
+const maxAvatarRequestSize = 10 << 20
+
+// UploadAvatar POST /v1/users/{id}/avatar
+func (uc *UserController) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarRequestSize)
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = user.ErrAvatarTooLarge
+		}
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+	defer file.Close()
+
+	ctx := r.Context()
+	var updatedUser *model.User
+	err = uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		updatedUser, err = uc.uploadAvatarAdapter.Execute(tctx, userID, file)
+		return err
+	})
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(updatedUser.Version))
+	response.JSON(w, http.StatusOK, newUserResponse(updatedUser))
+}
+
+// GetAvatar GET /v1/users/{id}/avatar
+func (uc *UserController) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	avatar, err := uc.getAvatarAdapter.Execute(r.Context(), userID)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	defer avatar.Close()
+
+	// sniff the content type from the head of the image
+	head := make([]byte, 512)
+	n, err := io.ReadFull(avatar, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		response.Error(w, r, err)
+		return
+	}
+	head = head[:n]
+
+	w.Header().Set("Content-Type", http.DetectContentType(head))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	w.Write(head)
+	io.Copy(w, avatar)
+}
+
+// etag builds the entity tag of the given user version
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseETag parses the If-Match header into a user version, "*" matches any version
+func parseETag(ifMatch string) (int, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, apperror.New(apperror.CodeInvalidArgument, fmt.Sprintf("invalid If-Match header: %s", ifMatch))
+	}
+
+	return version, nil
+}
+
+// RestoreUser PUT /v1/users/{id}/restore
+func (uc *UserController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	ctx := r.Context()
+	err = uc.dataManager.RunInTransaction(ctx, func(tctx context.Context) error {
+		return uc.restoreUserAdapter.Execute(tctx, userID)
+	})
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	response.JSON(w, http.StatusNoContent, "")
+}
+
+// Introspect POST /v1/introspect
+func (uc *UserController) Introspect(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, request.MaxBodySize)
+	if err := r.ParseForm(); err != nil {
+		response.Error(w, r, invalidArgument(err))
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		response.Error(w, r, errTokenRequired)
+		return
+	}
+
+	introspection, err := uc.introspectTokenAdapter.Execute(r.Context(), token)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, http.StatusOK, introspection)
+}
+
+// errTokenRequired is returned when introspecting without a token
+var errTokenRequired = apperror.New(apperror.CodeInvalidArgument, "token is required")
+
+// errIfMatchRequired is returned when updating without the If-Match header
+var errIfMatchRequired = apperror.New(apperror.CodePreconditionRequired, "If-Match header is required")
+
+// invalidArgument marks the request parsing errors as invalid argument, errors with a code are kept as is
+func invalidArgument(err error) error {
+	var appErr *apperror.Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return apperror.Wrap(err, apperror.CodeInvalidArgument, "invalid request")
+}
+
 // NewUserController creates a new user controller
 func NewUserController(
-	getLoginSessionAdapter userAdapter.GetLoginSessionAdapter,
-	loginAdapter userAdapter.LoginAdapter,
-	logoutAdapter userAdapter.LogoutAdapter,
-	changePasswordAdapter userAdapter.ChangePasswordAdapter,
+	adapters userAdapter.Adapters,
 	dataManager *data.Manager,
+	sessionCookie SessionCookie,
 ) *UserController {
 	return &UserController{
-		getLoginSessionAdapter: getLoginSessionAdapter,
-		loginAdapter:           loginAdapter,
-		logoutAdapter:          logoutAdapter,
-		changePasswordAdapter:  changePasswordAdapter,
-		dataManager:            dataManager,
+		getUserAdapter:          adapters.GetUser,
+		updateUserAdapter:       adapters.UpdateUser,
+		uploadAvatarAdapter:     adapters.UploadAvatar,
+		getAvatarAdapter:        adapters.GetAvatar,
+		getLoginSessionAdapter:  adapters.GetLoginSession,
+		loginAdapter:            adapters.Login,
+		logoutAdapter:           adapters.Logout,
+		changePasswordAdapter:   adapters.ChangePassword,
+		listUsersAdapter:        adapters.ListUsers,
+		listDeletedUsersAdapter: adapters.ListDeletedUsers,
+		restoreUserAdapter:      adapters.RestoreUser,
+		introspectTokenAdapter:  adapters.IntrospectToken,
+		dataManager:             dataManager,
+		sessionCookie:           sessionCookie,
 	}
 }
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/cors"
+)
+
+// CORSPolicy configures which browser origins can call the api
+type CORSPolicy struct {
+	// AllowedOrigins are full origins (https://app.example.com), a single * subdomain wildcard
+	// (https://*.example.com) or "null" for the pages opened from the disk. Empty only allows same origin calls
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// exposedHeaders are the response headers the browsers can read
+var exposedHeaders = []string{"Link", "ETag", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"}
+
+var corsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Validate rejects the policies letting any origin make credentialed calls, and the malformed ones
+func (p CORSPolicy) Validate() error {
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				return fmt.Errorf("cors: origin * is not allowed with credentials, list the origins")
+			}
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			return err
+		}
+	}
+
+	if len(p.AllowedMethods) == 0 {
+		return fmt.Errorf("cors: no allowed methods")
+	}
+	for _, method := range p.AllowedMethods {
+		if !corsMethods[method] {
+			return fmt.Errorf("cors: unknown method %q", method)
+		}
+	}
+
+	for _, header := range p.AllowedHeaders {
+		if header == "*" && p.AllowCredentials {
+			return fmt.Errorf("cors: header * is not allowed with credentials, list the headers")
+		}
+	}
+
+	if p.MaxAge < 0 {
+		return fmt.Errorf("cors: negative max age")
+	}
+
+	return nil
+}
+
+func validateOrigin(origin string) error {
+	if origin == "null" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("cors: invalid origin %q, expected scheme://host[:port]", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("cors: invalid origin %q, an origin has no path, query or credentials", origin)
+	}
+
+	host := u.Hostname()
+	if strings.Count(host, "*") > 1 || (strings.Contains(host, "*") && !strings.HasPrefix(host, "*.")) {
+		return fmt.Errorf("cors: invalid origin %q, only a single leading *. subdomain wildcard is supported", origin)
+	}
+	// *.com would allow any site of the top level domain
+	if strings.HasPrefix(host, "*.") && !strings.Contains(strings.TrimPrefix(host, "*."), ".") {
+		return fmt.Errorf("cors: origin %q is too wide", origin)
+	}
+
+	return nil
+}
+
+// handler builds the cors middleware of the policy
+func (p CORSPolicy) handler() func(next http.Handler) http.Handler {
+	return cors.New(cors.Options{
+		AllowedOrigins:   p.AllowedOrigins,
+		AllowedMethods:   p.AllowedMethods,
+		AllowedHeaders:   p.AllowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: p.AllowCredentials,
+		MaxAge:           int(p.MaxAge.Seconds()),
+	}).Handler
+}
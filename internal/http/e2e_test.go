@@ -0,0 +1,508 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"home24-technical-test/internal/http/controller"
+	"home24-technical-test/internal/user"
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+	"home24-technical-test/internal/user/storage/memory"
+	"home24-technical-test/pkg/blobstore"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/http/response"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	e2eEmail    = "user@home24.com"
+	e2ePassword = "user-password"
+	// e2eAdminEmail is granted the admin role, no user has it until createUser is called with it
+	e2eAdminEmail = "admin@home24.com"
+)
+
+// e2eServer serves the real router over the real services, the users & the sessions are kept in memory
+type e2eServer struct {
+	t      *testing.T
+	server *httptest.Server
+	users  *memory.MemoryStorage
+	// user is the user created with e2eEmail & e2ePassword
+	user *model.User
+}
+
+func newE2EServer(t *testing.T) *e2eServer {
+	users := memory.NewMemoryStorage()
+	svc := service.NewService(
+		user.NewService(users, blobstore.NewLocalStore(t.TempDir()), user.AttributeSchema{}, 1<<20),
+		user.NewSessionService(memory.NewSessionStorage(), []string{e2eAdminEmail}),
+	)
+
+	// the in memory storages have no transactions
+	s, err := NewServer(userAdapter.NewAdapters(svc), data.NewManager(nil), Settings{
+		SessionCookie:   controller.SessionCookie{Name: "sessionId", SameSite: http.SameSiteLaxMode},
+		CORSPolicy:      testCORSPolicy,
+		SecurityHeaders: testSecurityHeaders,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+
+	e := &e2eServer{
+		t:      t,
+		server: httptest.NewServer(s.compileRouter()),
+		users:  users,
+	}
+	t.Cleanup(e.server.Close)
+
+	e.user = e.createUser("user", e2eEmail, e2ePassword)
+	return e
+}
+
+// createUser stores a user able to log in with the password
+func (e *e2eServer) createUser(name, email, password string) *model.User {
+	e.t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		e.t.Fatalf("failed to hash the password: %v", err)
+	}
+	u := &model.User{Name: name, Email: email, Address: "Berlin", Password: string(hash)}
+	if err := e.users.Insert(context.Background(), u); err != nil {
+		e.t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return u
+}
+
+// avatarForm is a multipart form holding a png avatar, it returns the body & its content type
+func avatarForm(t *testing.T) (string, string) {
+	t.Helper()
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("failed to encode the avatar: %v", err)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatalf("failed to create the avatar part: %v", err)
+	}
+	part.Write(img.Bytes())
+	form.Close()
+
+	return body.String(), form.FormDataContentType()
+}
+
+// e2eResponse is a response read in full
+type e2eResponse struct {
+	t      *testing.T
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends the request, body is encoded to json unless it is already a string.
+// The request is authorized by the token when it's not empty
+func (e *e2eServer) do(method, path, token string, body interface{}, headers ...string) *e2eResponse {
+	e.t.Helper()
+
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			e.t.Fatalf("failed to encode the request body: %v", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, e.server.URL+path, reader)
+	if err != nil {
+		e.t.Fatalf("failed to build the request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "session "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := e.server.Client().Do(req)
+	if err != nil {
+		e.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		e.t.Fatalf("failed to read the response of %s %s: %v", method, path, err)
+	}
+
+	return &e2eResponse{t: e.t, status: resp.StatusCode, header: resp.Header, body: respBody}
+}
+
+// login logs in & returns the session
+func (e *e2eServer) login(email, password string) *public.LoginResponse {
+	e.t.Helper()
+
+	var session public.LoginResponse
+	e.do(http.MethodPost, "/v1/login", "", public.LoginParams{Email: email, Password: password}).
+		expectJSON(http.StatusOK, &session)
+	if session.SessionID == "" {
+		e.t.Fatal("expected a session id on login")
+	}
+	return &session
+}
+
+// expectStatus checks the status of the response
+func (r *e2eResponse) expectStatus(status int) *e2eResponse {
+	r.t.Helper()
+
+	if r.status != status {
+		r.t.Fatalf("expected status %d, got %d: %s", status, r.status, r.body)
+	}
+	return r
+}
+
+// expectJSON checks the status & decodes the json body into v
+func (r *e2eResponse) expectJSON(status int, v interface{}) {
+	r.t.Helper()
+
+	r.expectStatus(status)
+	if contentType := r.header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		r.t.Fatalf("expected a json response, got %s", contentType)
+	}
+	if err := json.Unmarshal(r.body, v); err != nil {
+		r.t.Fatalf("failed to decode the response %s: %v", r.body, err)
+	}
+}
+
+// expectProblem checks the response is a problem document of the status & the error code
+func (r *e2eResponse) expectProblem(status int, code string) *response.Problem {
+	r.t.Helper()
+
+	r.expectStatus(status)
+	if contentType := r.header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/problem+json") {
+		r.t.Fatalf("expected a problem response, got %s: %s", contentType, r.body)
+	}
+
+	var problem response.Problem
+	if err := json.Unmarshal(r.body, &problem); err != nil {
+		r.t.Fatalf("failed to decode the problem %s: %v", r.body, err)
+	}
+	if problem.Status != status || problem.Code != code || problem.Title == "" {
+		r.t.Fatalf("expected a problem with status %d & code %s, got %+v", status, code, problem)
+	}
+	return &problem
+}
+
+func TestE2E_Scenarios(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, e *e2eServer)
+	}{
+		{"login", func(t *testing.T, e *e2eServer) {
+			session := e.login(strings.ToUpper(e2eEmail), e2ePassword)
+			if session.CSRFToken == "" || session.ExpiredAt.IsZero() {
+				t.Errorf("expected a csrf token & an expiry, got %+v", session)
+			}
+			if session.User == nil || session.User.Email != e2eEmail || session.User.Password != "" {
+				t.Errorf("expected the user without its password, got %+v", session.User)
+			}
+		}},
+		{"login sets the session cookie", func(t *testing.T, e *e2eServer) {
+			resp := e.do(http.MethodPost, "/v1/login", "", public.LoginParams{Email: e2eEmail, Password: e2ePassword}).
+				expectStatus(http.StatusOK)
+			cookie := resp.header.Get("Set-Cookie")
+			if !strings.HasPrefix(cookie, "sessionId=") || !strings.Contains(cookie, "HttpOnly") {
+				t.Errorf("expected an http only session cookie, got %q", cookie)
+			}
+		}},
+		{"wrong password", func(t *testing.T, e *e2eServer) {
+			problem := e.do(http.MethodPost, "/v1/login", "", public.LoginParams{Email: e2eEmail, Password: "wrong-password"}).
+				expectProblem(http.StatusBadRequest, "invalid_argument")
+			if problem.Detail != user.ErrInvalidCredentials.Error() {
+				t.Errorf("expected %q, got %q", user.ErrInvalidCredentials.Error(), problem.Detail)
+			}
+		}},
+		{"unknown email", func(t *testing.T, e *e2eServer) {
+			// answered like a wrong password, the emails of the users aren't disclosed
+			problem := e.do(http.MethodPost, "/v1/login", "", public.LoginParams{Email: "nobody@home24.com", Password: e2ePassword}).
+				expectProblem(http.StatusBadRequest, "invalid_argument")
+			if problem.Detail != user.ErrInvalidCredentials.Error() {
+				t.Errorf("expected %q, got %q", user.ErrInvalidCredentials.Error(), problem.Detail)
+			}
+		}},
+		{"invalid login request", func(t *testing.T, e *e2eServer) {
+			problem := e.do(http.MethodPost, "/v1/login", "", `{"email":"not an email"}`).
+				expectProblem(http.StatusUnprocessableEntity, "validation_failed")
+			if len(problem.Errors) != 2 {
+				t.Errorf("expected the email & the password to be invalid, got %+v", problem.Errors)
+			}
+		}},
+		{"session lookup", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+
+			var found model.Session
+			e.do(http.MethodGet, "/v1/session", session.SessionID, nil).expectJSON(http.StatusOK, &found)
+			if found.ID != session.SessionID || found.Type != user.LoginSessionType {
+				t.Errorf("expected the login session %s, got %+v", session.SessionID, found)
+			}
+			if found.UserID() != e.user.ID || found.CSRFToken() != session.CSRFToken {
+				t.Errorf("expected the user & the csrf token in the session info, got %v", found.Info)
+			}
+		}},
+		{"logout", func(t *testing.T, e *e2eServer) {
+			e.createUser("other", "other@home24.com", e2ePassword)
+			other := e.login("other@home24.com", e2ePassword)
+			session := e.login(e2eEmail, e2ePassword)
+
+			resp := e.do(http.MethodPost, "/v1/logout", session.SessionID, nil).expectStatus(http.StatusNoContent)
+			if cookie := resp.header.Get("Set-Cookie"); !strings.Contains(cookie, "Max-Age=0") {
+				t.Errorf("expected the session cookie to be cleared, got %q", cookie)
+			}
+
+			e.do(http.MethodGet, "/v1/session", session.SessionID, nil).expectProblem(http.StatusUnauthorized, "unauthenticated")
+			e.do(http.MethodPost, "/v1/logout", session.SessionID, nil).expectProblem(http.StatusUnauthorized, "unauthenticated")
+			// the sessions of the other users are kept
+			e.do(http.MethodGet, "/v1/session", other.SessionID, nil).expectStatus(http.StatusOK)
+		}},
+		{"a new login revokes the previous session", func(t *testing.T, e *e2eServer) {
+			previous := e.login(e2eEmail, e2ePassword)
+			current := e.login(e2eEmail, e2ePassword)
+
+			e.do(http.MethodGet, "/v1/session", previous.SessionID, nil).expectProblem(http.StatusUnauthorized, "unauthenticated")
+			e.do(http.MethodGet, "/v1/session", current.SessionID, nil).expectStatus(http.StatusOK)
+		}},
+		{"password change", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+
+			e.do(http.MethodPut, "/v1/users/password", session.SessionID, public.ChangePasswordParams{
+				OldPassword: e2ePassword,
+				NewPassword: "new-password",
+			}).expectStatus(http.StatusNoContent)
+
+			e.do(http.MethodPost, "/v1/login", "", public.LoginParams{Email: e2eEmail, Password: e2ePassword}).
+				expectProblem(http.StatusBadRequest, "invalid_argument")
+			e.login(e2eEmail, "new-password")
+		}},
+		{"password change with a wrong password", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+
+			problem := e.do(http.MethodPut, "/v1/users/password", session.SessionID, public.ChangePasswordParams{
+				OldPassword: "wrong-password",
+				NewPassword: "new-password",
+			}).expectProblem(http.StatusBadRequest, "invalid_argument")
+			if problem.Detail != user.ErrWrongPassword.Error() {
+				t.Errorf("expected %q, got %q", user.ErrWrongPassword.Error(), problem.Detail)
+			}
+			e.login(e2eEmail, e2ePassword)
+		}},
+		{"password change with a short password", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+
+			problem := e.do(http.MethodPut, "/v1/users/password", session.SessionID, public.ChangePasswordParams{
+				OldPassword: e2ePassword,
+				NewPassword: "short",
+			}).expectProblem(http.StatusUnprocessableEntity, "validation_failed")
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != "newPassword" {
+				t.Errorf("expected the new password to be invalid, got %+v", problem.Errors)
+			}
+		}},
+		{"cookie session needs the csrf token", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+			cookie := "sessionId=" + session.SessionID
+
+			e.do(http.MethodGet, "/v1/session", "", nil, "Cookie", cookie).expectStatus(http.StatusOK)
+			e.do(http.MethodPost, "/v1/logout", "", nil, "Cookie", cookie).expectProblem(http.StatusForbidden, "permission_denied")
+			e.do(http.MethodPost, "/v1/logout", "", nil, "Cookie", cookie, "X-CSRF-Token", session.CSRFToken).
+				expectStatus(http.StatusNoContent)
+		}},
+		{"deleted users need the admin role", func(t *testing.T, e *e2eServer) {
+			other := e.createUser("other", "other@home24.com", e2ePassword)
+			if err := e.users.Delete(context.Background(), other.ID); err != nil {
+				t.Fatalf("failed to delete the user: %v", err)
+			}
+			session := e.login(e2eEmail, e2ePassword)
+
+			e.do(http.MethodGet, "/v1/users/deleted", session.SessionID, nil).expectProblem(http.StatusForbidden, "permission_denied")
+			e.do(http.MethodPut, fmt.Sprintf("/v1/users/%d/restore", other.ID), session.SessionID, nil).
+				expectProblem(http.StatusForbidden, "permission_denied")
+			if _, err := e.users.FindByID(context.Background(), other.ID); err != user.ErrNotFound {
+				t.Errorf("expected the user to stay deleted, got %v", err)
+			}
+
+			e.createUser("admin", e2eAdminEmail, e2ePassword)
+			admin := e.login(e2eAdminEmail, e2ePassword)
+
+			var page public.ListUsersResponse
+			e.do(http.MethodGet, "/v1/users/deleted", admin.SessionID, nil).expectJSON(http.StatusOK, &page)
+			if len(page.Users) != 1 || page.Users[0].ID != other.ID {
+				t.Errorf("expected the deleted user, got %+v", page.Users)
+			}
+			e.do(http.MethodPut, fmt.Sprintf("/v1/users/%d/restore", other.ID), admin.SessionID, nil).expectStatus(http.StatusNoContent)
+			if _, err := e.users.FindByID(context.Background(), other.ID); err != nil {
+				t.Errorf("expected the user to be restored, got %v", err)
+			}
+		}},
+		{"listing users needs the admin role", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+			e.do(http.MethodGet, "/v1/users?search=home24", session.SessionID, nil).expectProblem(http.StatusForbidden, "permission_denied")
+
+			e.createUser("admin", e2eAdminEmail, e2ePassword)
+			admin := e.login(e2eAdminEmail, e2ePassword)
+
+			var page public.ListUsersResponse
+			e.do(http.MethodGet, "/v1/users", admin.SessionID, nil).expectJSON(http.StatusOK, &page)
+			if len(page.Users) != 2 {
+				t.Errorf("expected the user & the admin, got %+v", page.Users)
+			}
+		}},
+		{"users only get themselves unless admin", func(t *testing.T, e *e2eServer) {
+			other := e.createUser("other", "other@home24.com", e2ePassword)
+			session := e.login(e2eEmail, e2ePassword)
+
+			e.do(http.MethodGet, fmt.Sprintf("/v1/users/%d", other.ID), session.SessionID, nil).expectProblem(http.StatusForbidden, "permission_denied")
+
+			var found public.UserResponse
+			resp := e.do(http.MethodGet, fmt.Sprintf("/v1/users/%d", e.user.ID), session.SessionID, nil)
+			resp.expectJSON(http.StatusOK, &found)
+			if found.Email != e2eEmail || resp.header.Get("ETag") == "" {
+				t.Errorf("expected the user to get itself with its etag, got %+v", found)
+			}
+
+			e.createUser("admin", e2eAdminEmail, e2ePassword)
+			admin := e.login(e2eAdminEmail, e2ePassword)
+			e.do(http.MethodGet, fmt.Sprintf("/v1/users/%d", other.ID), admin.SessionID, nil).expectJSON(http.StatusOK, &found)
+			if found.Email != "other@home24.com" {
+				t.Errorf("expected the admin to get the other user, got %+v", found)
+			}
+		}},
+		{"users only update themselves unless admin", func(t *testing.T, e *e2eServer) {
+			other := e.createUser("other", "other@home24.com", e2ePassword)
+			session := e.login(e2eEmail, e2ePassword)
+
+			e.do(http.MethodPut, fmt.Sprintf("/v1/users/%d", other.ID), session.SessionID, public.UpdateUserParams{Name: "renamed"}, "If-Match", "*").
+				expectProblem(http.StatusForbidden, "permission_denied")
+			e.do(http.MethodPut, "/v1/users/me", session.SessionID, public.UpdateUserParams{Name: "renamed"}, "If-Match", "*").
+				expectProblem(http.StatusForbidden, "permission_denied")
+			if found, _ := e.users.FindByID(context.Background(), other.ID); found.Name != "other" {
+				t.Errorf("expected the other user to be left untouched, got %+v", found)
+			}
+
+			var updated public.UserResponse
+			e.do(http.MethodPut, fmt.Sprintf("/v1/users/%d", e.user.ID), session.SessionID, public.UpdateUserParams{Name: "renamed"}, "If-Match", "*").
+				expectJSON(http.StatusOK, &updated)
+			if updated.Name != "renamed" {
+				t.Errorf("expected the user to rename itself, got %+v", updated)
+			}
+
+			e.createUser("admin", e2eAdminEmail, e2ePassword)
+			admin := e.login(e2eAdminEmail, e2ePassword)
+			e.do(http.MethodPut, fmt.Sprintf("/v1/users/%d", other.ID), admin.SessionID, public.UpdateUserParams{Name: "renamed"}, "If-Match", "*").
+				expectJSON(http.StatusOK, &updated)
+			if updated.Name != "renamed" {
+				t.Errorf("expected the admin to rename the other user, got %+v", updated)
+			}
+		}},
+		{"users only upload their own avatar unless admin", func(t *testing.T, e *e2eServer) {
+			other := e.createUser("other", "other@home24.com", e2ePassword)
+			session := e.login(e2eEmail, e2ePassword)
+			body, contentType := avatarForm(t)
+
+			e.do(http.MethodPost, fmt.Sprintf("/v1/users/%d/avatar", other.ID), session.SessionID, body, "Content-Type", contentType).
+				expectProblem(http.StatusForbidden, "permission_denied")
+			if found, _ := e.users.FindByID(context.Background(), other.ID); found.Avatar != "" {
+				t.Errorf("expected the other user to keep no avatar, got %q", found.Avatar)
+			}
+
+			e.do(http.MethodPost, fmt.Sprintf("/v1/users/%d/avatar", e.user.ID), session.SessionID, body, "Content-Type", contentType).
+				expectStatus(http.StatusOK)
+
+			e.createUser("admin", e2eAdminEmail, e2ePassword)
+			admin := e.login(e2eAdminEmail, e2ePassword)
+			e.do(http.MethodPost, fmt.Sprintf("/v1/users/%d/avatar", other.ID), admin.SessionID, body, "Content-Type", contentType).
+				expectStatus(http.StatusOK)
+			if found, _ := e.users.FindByID(context.Background(), other.ID); found.Avatar == "" {
+				t.Error("expected the admin to upload the avatar of the other user")
+			}
+		}},
+		{"deleted user", func(t *testing.T, e *e2eServer) {
+			session := e.login(e2eEmail, e2ePassword)
+			if err := e.users.Delete(context.Background(), e.user.ID); err != nil {
+				t.Fatalf("failed to delete the user: %v", err)
+			}
+
+			e.do(http.MethodGet, "/v1/session", session.SessionID, nil).expectProblem(http.StatusUnauthorized, "unauthenticated")
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newE2EServer(t))
+		})
+	}
+}
+
+func TestE2E_UnauthorizedAccess(t *testing.T) {
+	e := newE2EServer(t)
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodPost, "/v1/logout"},
+		{http.MethodGet, "/v1/session"},
+		{http.MethodGet, "/v1/users"},
+		{http.MethodPut, "/v1/users/password"},
+		{http.MethodGet, "/v1/users/deleted"},
+		{http.MethodGet, "/v1/users/1"},
+		{http.MethodPut, "/v1/users/1"},
+		{http.MethodPut, "/v1/users/1/restore"},
+		{http.MethodGet, "/v1/users/1/avatar"},
+		{http.MethodPost, "/v1/users/1/avatar"},
+	}
+	credentials := []struct {
+		name    string
+		token   string
+		headers []string
+		status  int
+		code    string
+	}{
+		{"no session", "", nil, http.StatusForbidden, "permission_denied"},
+		{"unknown session", "unknown-token", nil, http.StatusUnauthorized, "unauthenticated"},
+		{"unknown session cookie", "", []string{"Cookie", "sessionId=unknown-token"}, http.StatusUnauthorized, "unauthenticated"},
+		{"malformed authorization", "", []string{"Authorization", "Bearer"}, http.StatusForbidden, "permission_denied"},
+	}
+
+	for _, route := range routes {
+		for _, c := range credentials {
+			t.Run(route.method+" "+route.path+" "+c.name, func(t *testing.T) {
+				e := *e
+				e.t = t
+				e.do(route.method, route.path, c.token, nil, c.headers...).expectProblem(c.status, c.code)
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package http
+
+import (
+	"net/http"
+
+	rice "github.com/GeertJohan/go.rice"
+)
+
+// openAPIBox holds the OpenAPI document and the API reference page
+func openAPIBox() *rice.Box {
+	return rice.MustFindBox("./openapi")
+}
+
+// serveOpenAPI serves the OpenAPI document of the API
+func (s *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.openAPI.MustBytes("openapi.json"))
+}
+
+// serveDocs serves the API reference page rendering the OpenAPI document
+func (s *Server) serveDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(s.openAPI.MustBytes("docs.html"))
+}
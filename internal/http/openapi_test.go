@@ -0,0 +1,141 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"home24-technical-test/config"
+	"home24-technical-test/internal/http/controller"
+	userAdapter "home24-technical-test/internal/user/adapter"
+
+	"github.com/go-chi/chi"
+)
+
+// testCORSPolicy & testSecurityHeaders are valid settings for the tests
+var (
+	testCORSPolicy = CORSPolicy{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match"},
+		AllowCredentials: true,
+		MaxAge:           5 * time.Minute,
+	}
+	testSecurityHeaders = SecurityHeaders{
+		HSTSMaxAge:                365 * 24 * time.Hour,
+		ContentSecurityPolicy:     "default-src 'none'",
+		HTMLContentSecurityPolicy: "default-src 'self'",
+		ReferrerPolicy:            "no-referrer",
+	}
+)
+
+func newTestServer(corsPolicy CORSPolicy, securityHeaders SecurityHeaders) (*Server, error) {
+	return NewServer(userAdapter.Adapters{}, nil, Settings{
+		SessionCookie:   controller.SessionCookie{Name: "sessionId"},
+		CORSPolicy:      corsPolicy,
+		SecurityHeaders: securityHeaders,
+	})
+}
+
+func mustNewTestServer(t *testing.T) *Server {
+	s, err := newTestServer(testCORSPolicy, testSecurityHeaders)
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+	return s
+}
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	s := mustNewTestServer(t)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(s.openAPI.MustBytes("openapi.json"), &spec); err != nil {
+		t.Fatalf("openapi.json is not valid json: %v", err)
+	}
+
+	documented := map[string]bool{}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routed := map[string]bool{}
+	err := chi.Walk(s.compileRouter(), func(method string, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		routed[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk the router: %v", err)
+	}
+
+	for _, route := range difference(routed, documented) {
+		t.Errorf("route %s is not documented in openapi.json", route)
+	}
+	for _, route := range difference(documented, routed) {
+		t.Errorf("operation %s in openapi.json has no route", route)
+	}
+}
+
+func TestOpenAPI_Served(t *testing.T) {
+	r := mustNewTestServer(t).compileRouter()
+
+	for path, contentType := range map[string]string{
+		"/v1/openapi.json": "application/json",
+		"/v1/docs":         "text/html; charset=utf-8",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected status 200, got %d", path, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != contentType {
+			t.Errorf("GET %s: expected content type %q, got %q", path, contentType, got)
+		}
+	}
+}
+
+func TestOpenAPI_DocsScriptPinned(t *testing.T) {
+	w := httptest.NewRecorder()
+	mustNewTestServer(t).compileRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/docs", nil))
+
+	script := regexp.MustCompile(`<script src="([^"]+)"`).FindStringSubmatch(w.Body.String())
+	if script == nil {
+		t.Fatal("expected the docs page to load the redoc script")
+	}
+	if !regexp.MustCompile(`/redoc/v\d+\.\d+\.\d+/`).MatchString(script[1]) {
+		t.Errorf("expected an exact redoc version, got %s", script[1])
+	}
+
+	// the default policy of the docs page only allows the pinned script
+	cfg, err := config.GetConfiguration()
+	if err != nil {
+		t.Fatalf("failed to get the configuration: %v", err)
+	}
+	if !strings.Contains(cfg.HTMLContentSecurityPolicy, "script-src "+script[1]+";") {
+		t.Errorf("expected the docs policy to allow %s, got %s", script[1], cfg.HTMLContentSecurityPolicy)
+	}
+}
+
+// difference returns the sorted keys of a that are missing in b
+func difference(a, b map[string]bool) []string {
+	var missing []string
+	for key := range a {
+		if !b[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SecurityHeaders configures the security headers of every response
+type SecurityHeaders struct {
+	// HSTSMaxAge is the Strict-Transport-Security max age, zero leaves the header out (plain http)
+	HSTSMaxAge time.Duration
+	// ContentSecurityPolicy of the api responses
+	ContentSecurityPolicy string
+	// HTMLContentSecurityPolicy of the html views, e.g. the api reference page
+	HTMLContentSecurityPolicy string
+	ReferrerPolicy            string
+}
+
+var referrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// Validate rejects the incomplete configurations
+func (sh SecurityHeaders) Validate() error {
+	if sh.HSTSMaxAge < 0 {
+		return fmt.Errorf("security headers: negative hsts max age")
+	}
+	if sh.ContentSecurityPolicy == "" || sh.HTMLContentSecurityPolicy == "" {
+		return fmt.Errorf("security headers: content security policy is required")
+	}
+	if !referrerPolicies[sh.ReferrerPolicy] {
+		return fmt.Errorf("security headers: unknown referrer policy %q", sh.ReferrerPolicy)
+	}
+	return nil
+}
+
+// handler sets the security headers of the api responses
+func (sh SecurityHeaders) handler(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		if sh.HSTSMaxAge > 0 {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(sh.HSTSMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", sh.ReferrerPolicy)
+		h.Set("Content-Security-Policy", sh.ContentSecurityPolicy)
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// html replaces the content security policy of the html views
+func (sh SecurityHeaders) html(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Security-Policy", sh.HTMLContentSecurityPolicy)
+
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewServer_RejectsMisconfiguredCORS(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *CORSPolicy)
+	}{
+		{"any origin with credentials", func(p *CORSPolicy) { p.AllowedOrigins = []string{"*"} }},
+		{"misspelled method", func(p *CORSPolicy) { p.AllowedMethods = []string{"GET", "OPTION"} }},
+		{"no methods", func(p *CORSPolicy) { p.AllowedMethods = nil }},
+		{"origin without scheme", func(p *CORSPolicy) { p.AllowedOrigins = []string{"app.example.com"} }},
+		{"origin with path", func(p *CORSPolicy) { p.AllowedOrigins = []string{"https://app.example.com/login"} }},
+		{"top level domain wildcard", func(p *CORSPolicy) { p.AllowedOrigins = []string{"https://*.com"} }},
+		{"inner wildcard", func(p *CORSPolicy) { p.AllowedOrigins = []string{"https://app.*.example.com"} }},
+		{"any header with credentials", func(p *CORSPolicy) { p.AllowedHeaders = []string{"*"} }},
+		{"negative max age", func(p *CORSPolicy) { p.MaxAge = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testCORSPolicy
+			policy.AllowedOrigins = append([]string(nil), testCORSPolicy.AllowedOrigins...)
+			tt.modify(&policy)
+
+			if _, err := newTestServer(policy, testSecurityHeaders); err == nil {
+				t.Errorf("expected the cors policy %+v to be rejected", policy)
+			}
+		})
+	}
+}
+
+func TestNewServer_AcceptsValidCORS(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *CORSPolicy)
+	}{
+		{"listed origins", func(p *CORSPolicy) {}},
+		{"subdomain wildcard", func(p *CORSPolicy) { p.AllowedOrigins = []string{"https://*.example.com"} }},
+		{"local html views", func(p *CORSPolicy) { p.AllowedOrigins = []string{"null", "http://localhost:8089"} }},
+		{"any origin without credentials", func(p *CORSPolicy) {
+			p.AllowedOrigins = []string{"*"}
+			p.AllowCredentials = false
+		}},
+		{"same origin only", func(p *CORSPolicy) { p.AllowedOrigins = nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testCORSPolicy
+			tt.modify(&policy)
+
+			if _, err := newTestServer(policy, testSecurityHeaders); err != nil {
+				t.Errorf("expected the cors policy to be accepted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewServer_RejectsMisconfiguredSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(sh *SecurityHeaders)
+	}{
+		{"no content security policy", func(sh *SecurityHeaders) { sh.ContentSecurityPolicy = "" }},
+		{"no html content security policy", func(sh *SecurityHeaders) { sh.HTMLContentSecurityPolicy = "" }},
+		{"unknown referrer policy", func(sh *SecurityHeaders) { sh.ReferrerPolicy = "never" }},
+		{"negative hsts max age", func(sh *SecurityHeaders) { sh.HSTSMaxAge = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := testSecurityHeaders
+			tt.modify(&headers)
+
+			if _, err := newTestServer(testCORSPolicy, headers); err == nil {
+				t.Errorf("expected the security headers %+v to be rejected", headers)
+			}
+		})
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	r := mustNewTestServer(t).compileRouter()
+
+	tests := []struct {
+		path string
+		csp  string
+	}{
+		{"/v1/openapi.json", testSecurityHeaders.ContentSecurityPolicy},
+		{"/v1/docs", testSecurityHeaders.HTMLContentSecurityPolicy},
+		{"/v1/session", testSecurityHeaders.ContentSecurityPolicy},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		expected := map[string]string{
+			"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Referrer-Policy":           "no-referrer",
+			"Content-Security-Policy":   tt.csp,
+		}
+		for header, value := range expected {
+			if got := w.Header().Get(header); got != value {
+				t.Errorf("GET %s: expected %s %q, got %q", tt.path, header, value, got)
+			}
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	r := mustNewTestServer(t).compileRouter()
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://evil.example.org", false},
+		{"null", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodOptions, "/v1/login", nil)
+		req.Header.Set("Origin", tt.origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		got := w.Header().Get("Access-Control-Allow-Origin")
+		if tt.allowed && got != tt.origin {
+			t.Errorf("origin %s: expected to be allowed, got Access-Control-Allow-Origin %q", tt.origin, got)
+		}
+		if !tt.allowed && got != "" {
+			t.Errorf("origin %s: expected to be rejected, got Access-Control-Allow-Origin %q", tt.origin, got)
+		}
+	}
+}
@@ -4,18 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
 	"time"
 
 	"home24-technical-test/internal/http/controller"
+	"home24-technical-test/internal/user"
 	userAdapter "home24-technical-test/internal/user/adapter"
 	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/http/ratelimit"
+	"home24-technical-test/pkg/lifecycle"
 
+	rice "github.com/GeertJohan/go.rice"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
-	"github.com/rs/cors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 // Server represents http server
@@ -23,41 +27,104 @@ type Server struct {
 	userController         *controller.UserController
 	getUserAdapter         userAdapter.GetUserAdapter
 	getLoginSessionAdapter userAdapter.GetLoginSessionAdapter
+	openAPI                *rice.Box
+	grpcServer             *grpc.Server
+	clientCredentials      ClientCredentials
+	rateLimits             RateLimits
+	sessionCookie          controller.SessionCookie
+	corsPolicy             CORSPolicy
+	securityHeaders        SecurityHeaders
+	tls                    TLS
+	certReloader           *certReloader
+	timeouts               Timeouts
+	exposingPort           string
+	exposingGRPCPort       string
+	// ready tells whether the server takes traffic, always when nil
+	ready func() bool
+}
+
+// Settings are the settings of the server, the zero values disable the optional features
+type Settings struct {
+	// ExposingPort serves the http routes, ExposingGRPCPort the gRPC server if any
+	ExposingPort     string
+	ExposingGRPCPort string
+	// NewGRPCServer builds the gRPC server served next to the http server, nil serves no gRPC.
+	// With TLS the options hold the transport credentials of the http server
+	NewGRPCServer     func(opts ...grpc.ServerOption) *grpc.Server
+	ClientCredentials ClientCredentials
+	RateLimits        RateLimits
+	SessionCookie     controller.SessionCookie
+	CORSPolicy        CORSPolicy
+	SecurityHeaders   SecurityHeaders
+	TLS               TLS
+	Timeouts          Timeouts
+}
+
+// RateLimits are the rate limit policies of the routes, a nil Limiter disables rate limiting
+type RateLimits struct {
+	Limiter ratelimit.Limiter
+	// Login limits the login attempts per ip
+	Login ratelimit.Policy
+	// API limits the authorized routes per user
+	API ratelimit.Policy
+	// Introspect limits the token introspection per ip & per api key
+	Introspect ratelimit.Policy
+	// TrustedProxies can forward the client ip the requests are limited by, none limits by the socket peer
+	TrustedProxies ratelimit.TrustedProxies
+}
+
+// rateLimit limits the requests of the route with the policy, once per key func
+func (s *Server) rateLimit(policy ratelimit.Policy, keyFuncs ...ratelimit.KeyFunc) func(next http.Handler) http.Handler {
+	if s.rateLimits.Limiter == nil {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+	return ratelimit.Middleware(s.rateLimits.Limiter, policy, keyFuncs...)
 }
 
 func (s *Server) compileRouter() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
+	r.Use(s.serviceIdentity)
 
 	r.Use(middleware.Timeout(60 * time.Second))
-	cors := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTION"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Access-Token", "X-Requested-With"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	})
-	r.Use(cors.Handler)
+	r.Use(s.securityHeaders.handler)
+	r.Use(s.corsPolicy.handler())
 
 	// Prometheus handler
 	//
 
 	// Add routes
 	//
-	r.HandleFunc("/v1/login", s.userController.Login)
+	byIP := ratelimit.ByIP(s.rateLimits.TrustedProxies)
+	r.With(s.rateLimit(s.rateLimits.Login, byIP)).Post("/v1/login", s.userController.Login)
+	r.Get("/readyz", s.readiness)
+	r.Get("/v1/openapi.json", s.serveOpenAPI)
+	r.With(s.securityHeaders.html).Get("/v1/docs", s.serveDocs)
+	r.With(
+		s.rateLimit(s.rateLimits.Introspect, byIP, ratelimit.ByAPIKey),
+		s.clientsOnly(s.clientCredentials),
+	).Post("/v1/introspect", s.userController.Introspect)
 	r.Route("/v1", func(r chi.Router) {
 		r.Use(s.authorizedOnly(s.getUserAdapter, s.getLoginSessionAdapter))
+		r.Use(s.rateLimit(s.rateLimits.API, ratelimit.ByUserID))
 
 		r.Post("/logout", s.userController.Logout)
 		r.Get("/session", s.userController.GetLoginSession)
 
 		r.Group(func(r chi.Router) {
 			r.Route("/users", func(r chi.Router) {
+				r.With(s.requireRole(user.AdminRole)).Get("/", s.userController.ListUsers)
 				r.Put("/password", s.userController.ChangePassword)
+				r.With(s.requireRole(user.AdminRole)).Get("/deleted", s.userController.ListDeletedUsers)
+				r.With(s.requireRole(user.AdminRole)).Put("/{id}/restore", s.userController.RestoreUser)
+				r.With(s.selfOrRole(user.AdminRole)).Get("/{id}", s.userController.GetUser)
+				r.With(s.selfOrRole(user.AdminRole)).Put("/{id}", s.userController.UpdateUser)
+				r.With(s.selfOrRole(user.AdminRole)).Post("/{id}/avatar", s.userController.UploadAvatar)
+				r.Get("/{id}/avatar", s.userController.GetAvatar)
 			})
 		})
 
@@ -66,49 +133,157 @@ func (s *Server) compileRouter() chi.Router {
 	return r
 }
 
-// Serve encapsulate process to listen and serve
-func (s *Server) Serve(exposingPort string) {
-	// Compile all the routes
-	r := s.compileRouter()
+// Handler returns the http handler serving all the routes
+func (s *Server) Handler() http.Handler {
+	return s.compileRouter()
+}
 
-	// Run the server
-	log.Printf("About to listen on %s. Go to http://127.0.0.1:%s", exposingPort, exposingPort)
-	srv := http.Server{Addr: fmt.Sprintf(":%s", exposingPort), Handler: r}
+// Start listens on the ports and hands the servers to the lifecycle manager, which serves them until the shutdown.
+// The gRPC server (if any) is served next to the http server. With TLS the server listens on https, the gRPC server
+// on tls too, and the redirect port (if any) redirects the plain http requests to it
+func (s *Server) Start(lc *lifecycle.Manager) error {
+	s.ready = lc.Ready
+	exposingPort, exposingGRPCPort := s.exposingPort, s.exposingGRPCPort
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen: %s\n", err)
+	srv := s.httpServer(exposingPort, s.compileRouter())
+	lis, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return fmt.Errorf("listen: %v", err)
+	}
+
+	if s.certReloader != nil {
+		srv.TLSConfig = s.certReloader.tlsConfig()
+		lc.Go("tls reloader", func(ctx context.Context) error {
+			s.certReloader.watch(ctx)
+			return nil
+		})
+
+		log.Printf("About to listen on %s. Go to https://127.0.0.1:%s", exposingPort, exposingPort)
+		lc.Serve("http", func() error { return srv.ServeTLS(lis, "", "") }, srv.Shutdown)
+
+		if s.tls.RedirectPort != "" {
+			redirectSrv := s.httpServer(s.tls.RedirectPort, redirectToHTTPS(exposingPort))
+			redirectLis, err := net.Listen("tcp", redirectSrv.Addr)
+			if err != nil {
+				lis.Close()
+				return fmt.Errorf("listen redirect: %v", err)
+			}
+
+			log.Printf("About to redirect %s to https", s.tls.RedirectPort)
+			lc.Serve("http redirect", func() error { return redirectSrv.Serve(redirectLis) }, redirectSrv.Shutdown)
 		}
-	}()
+	} else {
+		log.Printf("About to listen on %s. Go to http://127.0.0.1:%s", exposingPort, exposingPort)
+		lc.Serve("http", func() error { return srv.Serve(lis) }, srv.Shutdown)
+	}
+
+	if s.grpcServer != nil {
+		grpcLis, err := net.Listen("tcp", fmt.Sprintf(":%s", exposingGRPCPort))
+		if err != nil {
+			lis.Close()
+			return fmt.Errorf("listen grpc: %v", err)
+		}
+
+		if s.certReloader != nil {
+			log.Printf("About to serve gRPC over tls on %s", exposingGRPCPort)
+		} else {
+			log.Printf("About to serve gRPC on %s", exposingGRPCPort)
+		}
+		lc.Serve("grpc", func() error { return s.grpcServer.Serve(grpcLis) }, func(ctx context.Context) error {
+			stopGRPC(ctx, s.grpcServer)
+			return nil
+		})
+	}
+
+	return nil
+}
+
+// httpServer creates a http server with the timeouts
+func (s *Server) httpServer(port string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", port),
+		Handler:           handler,
+		ReadTimeout:       s.timeouts.Read,
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+	}
+}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+// readiness answers the readiness probe, the load balancers stop sending requests once the shutdown started
+func (s *Server) readiness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	if s.ready != nil && !s.ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
 
-	<-quit
+// stopGRPC waits for the pending gRPC calls to finish, they are cancelled once ctx is done
+func stopGRPC(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
 
-	log.Println("Shutdown Server ...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatal("Server Shutdown:", err)
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
 	}
-	log.Println("Server exiting")
 }
 
 // NewServer create new http server
-func NewServer(
-	getUserAdapter userAdapter.GetUserAdapter,
-	getLoginSessionAdapter userAdapter.GetLoginSessionAdapter,
-	loginAdapter userAdapter.LoginAdapter,
-	logoutAdapter userAdapter.LogoutAdapter,
-	changePasswordAdapter userAdapter.ChangePasswordAdapter,
-	dataManager *data.Manager,
-) *Server {
-	userController := controller.NewUserController(getLoginSessionAdapter, loginAdapter, logoutAdapter, changePasswordAdapter, dataManager)
+func NewServer(adapters userAdapter.Adapters, dataManager *data.Manager, settings Settings) (*Server, error) {
+	if err := settings.CORSPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := settings.SecurityHeaders.Validate(); err != nil {
+		return nil, err
+	}
+	if err := settings.TLS.Validate(); err != nil {
+		return nil, err
+	}
+	if err := settings.Timeouts.Validate(); err != nil {
+		return nil, err
+	}
+
+	var reloader *certReloader
+	if settings.TLS.Enabled() {
+		var err error
+		if reloader, err = newCertReloader(settings.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	var grpcServer *grpc.Server
+	if settings.NewGRPCServer != nil {
+		var opts []grpc.ServerOption
+		if reloader != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.tlsConfig())))
+		}
+		grpcServer = settings.NewGRPCServer(opts...)
+	}
+
+	userController := controller.NewUserController(adapters, dataManager, settings.SessionCookie)
 
 	return &Server{
 		userController:         userController,
-		getUserAdapter:         getUserAdapter,
-		getLoginSessionAdapter: getLoginSessionAdapter,
-	}
+		getUserAdapter:         adapters.GetUser,
+		getLoginSessionAdapter: adapters.GetLoginSession,
+		openAPI:                openAPIBox(),
+		grpcServer:             grpcServer,
+		clientCredentials:      settings.ClientCredentials,
+		rateLimits:             settings.RateLimits,
+		sessionCookie:          settings.SessionCookie,
+		corsPolicy:             settings.CORSPolicy,
+		securityHeaders:        settings.SecurityHeaders,
+		tls:                    settings.TLS,
+		certReloader:           reloader,
+		timeouts:               settings.Timeouts,
+		exposingPort:           settings.ExposingPort,
+		exposingGRPCPort:       settings.ExposingGRPCPort,
+	}, nil
 }
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/http/response"
+)
+
+var errUnknownClientCertificate = apperror.New(apperror.CodePermissionDenied, "unknown client certificate")
+
+// nextProtos are the protocols negotiated with ALPN, http/2 is only served when offered
+var nextProtos = []string{"h2", "http/1.1"}
+
+// TLS are the https settings of the server, an empty CertFile serves plain http
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mTLS, the client certificates signed by these CAs are verified
+	ClientCAFile string
+	// RequireClientCert rejects the connections without a client certificate, otherwise it's optional
+	RequireClientCert bool
+	// ServiceIdentities maps the subject common names of the client certificates to the service identities
+	ServiceIdentities map[string]string
+	// ReloadInterval is how often the files are checked for changes, zero disables the reload
+	ReloadInterval time.Duration
+	// RedirectPort is the plain http port redirecting to https, empty disables it
+	RedirectPort string
+}
+
+// Enabled tells whether the server is served over https
+func (t TLS) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// Validate rejects the incomplete settings
+func (t TLS) Validate() error {
+	if !t.Enabled() {
+		if t.KeyFile != "" || t.ClientCAFile != "" || t.RedirectPort != "" {
+			return fmt.Errorf("tls: a certificate file is required")
+		}
+		return nil
+	}
+
+	if t.KeyFile == "" {
+		return fmt.Errorf("tls: a key file is required")
+	}
+	if t.ClientCAFile == "" && (t.RequireClientCert || len(t.ServiceIdentities) > 0) {
+		return fmt.Errorf("tls: client certificates need a client ca file")
+	}
+	if t.ReloadInterval < 0 {
+		return fmt.Errorf("tls: negative reload interval")
+	}
+
+	return nil
+}
+
+// Timeouts of the http server, zero means no timeout
+type Timeouts struct {
+	Read       time.Duration
+	ReadHeader time.Duration
+	// Write must be longer than the 60s handler timeout or the slow responses are cut
+	Write time.Duration
+	Idle  time.Duration
+}
+
+// Validate rejects the negative timeouts
+func (t Timeouts) Validate() error {
+	if t.Read < 0 || t.ReadHeader < 0 || t.Write < 0 || t.Idle < 0 {
+		return fmt.Errorf("http: negative timeout")
+	}
+	return nil
+}
+
+// certReloader serves the certificate & client CAs of the files, reloaded once they change
+type certReloader struct {
+	settings TLS
+
+	mu      sync.RWMutex
+	config  *tls.Config
+	modTime time.Time
+}
+
+// newCertReloader loads the files, a missing or invalid file fails right away
+func newCertReloader(settings TLS) (*certReloader, error) {
+	cr := &certReloader{settings: settings}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *certReloader) load() error {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.settings.CertFile, cr.settings.KeyFile)
+	if err != nil {
+		return fmt.Errorf("tls: failed to load the certificate: %v", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   nextProtos,
+	}
+
+	if cr.settings.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cr.settings.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("tls: failed to read the client ca: %v", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("tls: no certificate found in the client ca file")
+		}
+
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if cr.settings.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	cr.mu.Lock()
+	cr.config = config
+	cr.modTime = modTime
+	cr.mu.Unlock()
+
+	return nil
+}
+
+// latestModTime is the modification time of the most recently changed file
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{cr.settings.CertFile, cr.settings.KeyFile, cr.settings.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, fmt.Errorf("tls: %v", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// watch reloads the files when they change until ctx is done,
+// a failed reload (e.g. a certificate written before its key) keeps the previous files
+func (cr *certReloader) watch(ctx context.Context) {
+	if cr.settings.ReloadInterval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cr.settings.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modTime, err := cr.latestModTime()
+			if err != nil {
+				log.Printf("failed to check the tls files: %v", err)
+				continue
+			}
+
+			cr.mu.RLock()
+			changed := !modTime.Equal(cr.modTime)
+			cr.mu.RUnlock()
+			if !changed {
+				continue
+			}
+
+			if err := cr.load(); err != nil {
+				log.Printf("failed to reload the tls files: %v", err)
+				continue
+			}
+			log.Println("tls files reloaded")
+		}
+	}
+}
+
+// getConfigForClient hands the current files to every new connection
+func (cr *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.config, nil
+}
+
+// tlsConfig is the server config, the certificates come from the reloader.
+// The config of the reloader replaces this one for the handshake, both offer the same protocols
+func (cr *certReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         nextProtos,
+		GetConfigForClient: cr.getConfigForClient,
+	}
+}
+
+// serviceIdentity puts the service identity of the verified client certificate into the context,
+// certificates without an identity are rejected
+func (s *Server) serviceIdentity(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		subject := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		identity, ok := s.tls.ServiceIdentities[subject]
+		if !ok {
+			response.Error(w, r, errUnknownClientCertificate)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), appcontext.KeyServiceIdentity, identity)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// redirectToHTTPS redirects the plain http requests to the https port
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// no port in the host
+			host = strings.Trim(r.Host, "[]")
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		} else if strings.Contains(host, ":") {
+			// ipv6 address
+			host = "[" + host + "]"
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
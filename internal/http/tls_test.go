@@ -0,0 +1,323 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/grpc/userpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testCA signs the certificates of the tests
+type testCA struct {
+	t    *testing.T
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the ca key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the ca: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse the ca: %v", err)
+	}
+
+	return &testCA{t: t, cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a certificate of the common name, it returns the certificate & key pem
+func (ca *testCA) issue(commonName string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		ca.t.Fatalf("failed to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		ca.t.Fatalf("failed to create the certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		ca.t.Fatalf("failed to encode the key: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTLSFiles writes a server certificate of the serial & the client ca into dir
+func (ca *testCA) writeTLSFiles(dir string, serial int64) TLS {
+	settings := TLS{
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+	certPEM, keyPEM := ca.issue("server", serial, x509.ExtKeyUsageServerAuth)
+	for file, content := range map[string][]byte{settings.CertFile: certPEM, settings.KeyFile: keyPEM, settings.ClientCAFile: ca.pem} {
+		if err := ioutil.WriteFile(file, content, 0600); err != nil {
+			ca.t.Fatalf("failed to write %s: %v", file, err)
+		}
+	}
+	return settings
+}
+
+// servedSerial is the serial of the certificate the reloader serves
+func servedSerial(t *testing.T, cr *certReloader) int64 {
+	t.Helper()
+
+	config, err := cr.getConfigForClient(nil)
+	if err != nil {
+		t.Fatalf("failed to get the config: %v", err)
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("failed to parse the served certificate: %v", err)
+	}
+	return cert.SerialNumber.Int64()
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	settings := ca.writeTLSFiles(dir, 10)
+	settings.ReloadInterval = 10 * time.Millisecond
+
+	cr, err := newCertReloader(settings)
+	if err != nil {
+		t.Fatalf("failed to load the files: %v", err)
+	}
+	if serial := servedSerial(t, cr); serial != 10 {
+		t.Fatalf("expected the certificate 10, got %d", serial)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cr.watch(ctx)
+
+	// a broken key keeps the previous certificate
+	if err := ioutil.WriteFile(settings.KeyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("failed to break the key: %v", err)
+	}
+	touch(t, settings.KeyFile, time.Now().Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if serial := servedSerial(t, cr); serial != 10 {
+		t.Fatalf("expected the certificate 10 to be kept, got %d", serial)
+	}
+
+	ca.writeTLSFiles(dir, 11)
+	touch(t, settings.CertFile, time.Now().Add(2*time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for servedSerial(t, cr) != 11 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the certificate 11 to be reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCertReloader_Protocols(t *testing.T) {
+	ca := newTestCA(t)
+	cr, err := newCertReloader(ca.writeTLSFiles(t.TempDir(), 10))
+	if err != nil {
+		t.Fatalf("failed to load the files: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = cr.tlsConfig()
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, protocols := range [][]string{{"h2", "http/1.1"}, {"http/1.1"}} {
+		conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{RootCAs: roots, NextProtos: protocols})
+		if err != nil {
+			t.Fatalf("failed to connect: %v", err)
+		}
+		negotiated := conn.ConnectionState().NegotiatedProtocol
+		conn.Close()
+		if negotiated != protocols[0] {
+			t.Errorf("expected %s to be negotiated, got %q", protocols[0], negotiated)
+		}
+	}
+}
+
+func TestNewServer_GRPCOverTLS(t *testing.T) {
+	ca := newTestCA(t)
+	s, err := NewServer(userAdapter.Adapters{}, nil, Settings{
+		CORSPolicy:      testCORSPolicy,
+		SecurityHeaders: testSecurityHeaders,
+		TLS:             ca.writeTLSFiles(t.TempDir(), 10),
+		NewGRPCServer: func(opts ...grpc.ServerOption) *grpc.Server {
+			return grpc.NewServer(opts...)
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.grpcServer.Serve(lis)
+	defer s.grpcServer.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	call := func(transport grpc.DialOption) error {
+		t.Helper()
+
+		conn, err := grpc.Dial(lis.Addr().String(), transport)
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		return conn.Invoke(ctx, "/home24.user.v1.UserService/Login", &userpb.LoginRequest{}, &userpb.LoginResponse{})
+	}
+
+	// the handshake succeeds, the service isn't registered on this server
+	if err := call(grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: roots}))); status.Code(err) != codes.Unimplemented {
+		t.Errorf("expected the call over tls to reach the server, got %v", err)
+	}
+	if err := call(grpc.WithInsecure()); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected the plain text call to be refused, got %v", err)
+	}
+}
+
+func TestServiceIdentity(t *testing.T) {
+	ca := newTestCA(t)
+	settings := ca.writeTLSFiles(t.TempDir(), 10)
+	settings.ServiceIdentities = map[string]string{"orders": "orders-service"}
+	cr, err := newCertReloader(settings)
+	if err != nil {
+		t.Fatalf("failed to load the files: %v", err)
+	}
+
+	s := &Server{tls: settings}
+	server := httptest.NewUnstartedServer(s.serviceIdentity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(appcontext.ServiceIdentity(r.Context())))
+	})))
+	server.TLS = cr.tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(clientCommonName string) (int, string) {
+		t.Helper()
+
+		config := &tls.Config{RootCAs: roots}
+		if clientCommonName != "" {
+			certPEM, keyPEM := ca.issue(clientCommonName, 20, x509.ExtKeyUsageClientAuth)
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatalf("failed to load the client certificate: %v", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, identity := get("orders"); status != http.StatusOK || identity != "orders-service" {
+		t.Errorf("expected the identity of the known certificate, got %d %q", status, identity)
+	}
+	if status, _ := get("unknown"); status != http.StatusForbidden {
+		t.Errorf("expected the unknown certificate to be rejected, got %d", status)
+	}
+	if status, identity := get(""); status != http.StatusOK || identity != "" {
+		t.Errorf("expected no identity without certificate, got %d %q", status, identity)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		host      string
+		httpsPort string
+		expected  string
+	}{
+		{"example.com", "443", "https://example.com/v1/users?limit=10"},
+		{"example.com:80", "443", "https://example.com/v1/users?limit=10"},
+		{"example.com:8080", "8443", "https://example.com:8443/v1/users?limit=10"},
+		{"[::1]:8080", "443", "https://[::1]/v1/users?limit=10"},
+		{"[::1]:8080", "8443", "https://[::1]:8443/v1/users?limit=10"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/v1/users?limit=10", nil)
+		w := httptest.NewRecorder()
+		redirectToHTTPS(tt.httpsPort).ServeHTTP(w, r)
+
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.expected {
+			t.Errorf("%s to port %s: expected a redirect to %s, got %d %s", tt.host, tt.httpsPort, tt.expected, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+// touch sets the modification time of the file
+func touch(t *testing.T, file string, modTime time.Time) {
+	t.Helper()
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatalf("failed to touch %s: %v", file, err)
+	}
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"home24-technical-test/config"
+	"home24-technical-test/database"
+	"home24-technical-test/internal/http/controller"
+	"home24-technical-test/internal/user"
+	userAdapter "home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+	"home24-technical-test/internal/user/storage/memory"
+	"home24-technical-test/internal/user/storage/sqlite"
+	"home24-technical-test/pkg/blobstore"
+	"home24-technical-test/pkg/data"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// failingSessionStorage is a memory session storage failing to update the sessions of a user when told so
+type failingSessionStorage struct {
+	*memory.SessionStorage
+	failUpdate bool
+}
+
+func (s *failingSessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
+	if s.failUpdate {
+		return errors.New("session storage is down")
+	}
+	return s.SessionStorage.UpdateByUserID(ctx, session)
+}
+
+// newSQLiteE2EServer serves the real router over the sqlite user storage, the requests run in real transactions
+func newSQLiteE2EServer(t *testing.T, sessions user.SessionStorage) (*e2eServer, *sqlx.DB) {
+	connectionString := "sqlite://" + filepath.Join(t.TempDir(), "users.db")
+	if err := database.MigrateUp(&config.Config{DBConnectionString: connectionString}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db, err := database.Open(connectionString)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	users := sqlite.NewSQLiteStorage(db)
+	svc := service.NewService(
+		user.NewService(users, blobstore.NewLocalStore(t.TempDir()), user.AttributeSchema{}, 1<<20),
+		user.NewSessionService(sessions, nil),
+	)
+
+	s, err := NewServer(userAdapter.NewAdapters(svc), data.NewManager(db), Settings{
+		SessionCookie:   controller.SessionCookie{Name: "sessionId", SameSite: http.SameSiteLaxMode},
+		CORSPolicy:      testCORSPolicy,
+		SecurityHeaders: testSecurityHeaders,
+	})
+	if err != nil {
+		t.Fatalf("failed to create the server: %v", err)
+	}
+
+	e := &e2eServer{
+		t:      t,
+		server: httptest.NewServer(s.compileRouter()),
+	}
+	t.Cleanup(e.server.Close)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(e2ePassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash the password: %v", err)
+	}
+	e.user = &model.User{Name: "user", Email: e2eEmail, Address: "Berlin", Password: string(hash)}
+	if err := users.Insert(context.Background(), e.user); err != nil {
+		t.Fatalf("failed to create the user: %v", err)
+	}
+
+	return e, db
+}
+
+func TestE2E_TransactionRollback(t *testing.T) {
+	sessions := &failingSessionStorage{SessionStorage: memory.NewSessionStorage()}
+	e, db := newSQLiteE2EServer(t, sessions)
+	session := e.login(e2eEmail, e2ePassword)
+
+	// the user is updated before its sessions, the failing session update rolls the user update back
+	sessions.failUpdate = true
+	e.do(http.MethodPut, "/v1/users/"+strconv.Itoa(e.user.ID), session.SessionID, public.UpdateUserParams{
+		Name: "renamed",
+	}, "If-Match", "*").expectProblem(http.StatusInternalServerError, "internal")
+
+	found, err := sqlite.NewSQLiteStorage(db).FindByID(context.Background(), e.user.ID)
+	if err != nil {
+		t.Fatalf("failed to find the user: %v", err)
+	}
+	if found.Name != "user" || found.Version != e.user.Version {
+		t.Errorf("expected the update to be rolled back, got %+v", found)
+	}
+
+	sessions.failUpdate = false
+	var updated model.User
+	e.do(http.MethodPut, "/v1/users/"+strconv.Itoa(e.user.ID), session.SessionID, public.UpdateUserParams{
+		Name: "renamed",
+	}, "If-Match", "*").expectJSON(http.StatusOK, &updated)
+	if updated.Name != "renamed" {
+		t.Errorf("expected the user to be renamed, got %+v", updated)
+	}
+}
+
+func TestE2E_SingleConnection(t *testing.T) {
+	e, db := newSQLiteE2EServer(t, memory.NewSessionStorage())
+	// the queries of a request run on the connection of its transaction, a request waiting for a second
+	// connection would never get it
+	db.SetMaxOpenConns(1)
+	e.server.Client().Timeout = 10 * time.Second
+
+	session := e.login(e2eEmail, e2ePassword)
+	e.do(http.MethodGet, "/v1/users/"+strconv.Itoa(e.user.ID), session.SessionID, nil).expectStatus(http.StatusOK)
+	e.do(http.MethodPut, "/v1/users/"+strconv.Itoa(e.user.ID), session.SessionID, public.UpdateUserParams{
+		Name: "renamed",
+	}, "If-Match", "*").expectStatus(http.StatusOK)
+	e.do(http.MethodPut, "/v1/users/password", session.SessionID, public.ChangePasswordParams{
+		OldPassword: e2ePassword,
+		NewPassword: "new-password",
+	}).expectStatus(http.StatusNoContent)
+	e.do(http.MethodPost, "/v1/logout", session.SessionID, nil).expectStatus(http.StatusNoContent)
+	e.login(e2eEmail, "new-password")
+}
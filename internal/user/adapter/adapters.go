@@ -0,0 +1,41 @@
+package adapter
+
+import (
+	"home24-technical-test/internal/user/service"
+)
+
+// Adapters groups the user adapters, the http & gRPC servers take the ones they need from it
+type Adapters struct {
+	GetUser          GetUserAdapter
+	GetLoginSession  GetLoginSessionAdapter
+	Login            LoginAdapter
+	Logout           LogoutAdapter
+	ChangePassword   ChangePasswordAdapter
+	UpdateUser       UpdateUserAdapter
+	UploadAvatar     UploadAvatarAdapter
+	GetAvatar        GetAvatarAdapter
+	ListUsers        ListUsersAdapter
+	ListDeletedUsers ListDeletedUsersAdapter
+	RestoreUser      RestoreUserAdapter
+	IntrospectToken  IntrospectTokenAdapter
+}
+
+// NewAdapters builds all the user adapters on top of the service
+func NewAdapters(
+	service service.ServiceInterface,
+) Adapters {
+	return Adapters{
+		GetUser:          NewGetUserAdapter(service),
+		GetLoginSession:  NewGetLoginSessionAdapter(service),
+		Login:            NewLoginAdapter(service),
+		Logout:           NewLogoutAdapter(service),
+		ChangePassword:   NewChangePasswordAdapter(service),
+		UpdateUser:       NewUpdateUserAdapter(service),
+		UploadAvatar:     NewUploadAvatarAdapter(service),
+		GetAvatar:        NewGetAvatarAdapter(service),
+		ListUsers:        NewListUsersAdapter(service),
+		ListDeletedUsers: NewListDeletedUsersAdapter(service),
+		RestoreUser:      NewRestoreUserAdapter(service),
+		IntrospectToken:  NewIntrospectTokenAdapter(service),
+	}
+}
@@ -0,0 +1,27 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/service"
+	"io"
+)
+
+// GetAvatarAdapter encapsulate process for get avatar in adapter
+type GetAvatarAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewGetAvatarAdapter build an adapter for get avatar
+func NewGetAvatarAdapter(
+	service service.ServiceInterface,
+) GetAvatarAdapter {
+	return GetAvatarAdapter{
+		service: service,
+	}
+}
+
+func (r GetAvatarAdapter) Execute(ctx context.Context, userID int) (io.ReadCloser, error) {
+	result, err := r.service.GetAvatar(ctx, userID)
+
+	return result, err
+}
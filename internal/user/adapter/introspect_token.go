@@ -0,0 +1,27 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+)
+
+// IntrospectTokenAdapter encapsulate process for introspect token in adapter
+type IntrospectTokenAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewIntrospectTokenAdapter build an adapter for introspect token
+func NewIntrospectTokenAdapter(
+	service service.ServiceInterface,
+) IntrospectTokenAdapter {
+	return IntrospectTokenAdapter{
+		service: service,
+	}
+}
+
+func (r IntrospectTokenAdapter) Execute(ctx context.Context, token string) (*public.IntrospectionResponse, error) {
+	result, err := r.service.IntrospectToken(ctx, token)
+
+	return result, err
+}
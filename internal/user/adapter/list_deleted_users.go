@@ -0,0 +1,27 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+)
+
+// ListDeletedUsersAdapter encapsulate process for list deleted users in adapter
+type ListDeletedUsersAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewListDeletedUsersAdapter build an adapter for list deleted users
+func NewListDeletedUsersAdapter(
+	service service.ServiceInterface,
+) ListDeletedUsersAdapter {
+	return ListDeletedUsersAdapter{
+		service: service,
+	}
+}
+
+func (r ListDeletedUsersAdapter) Execute(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	result, err := r.service.ListDeletedUsers(ctx, params)
+
+	return result, err
+}
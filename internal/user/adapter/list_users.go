@@ -0,0 +1,27 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+)
+
+// ListUsersAdapter encapsulate process for list users in adapter
+type ListUsersAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewListUsersAdapter build an adapter for list users
+func NewListUsersAdapter(
+	service service.ServiceInterface,
+) ListUsersAdapter {
+	return ListUsersAdapter{
+		service: service,
+	}
+}
+
+func (r ListUsersAdapter) Execute(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	result, err := r.service.ListUsers(ctx, params)
+
+	return result, err
+}
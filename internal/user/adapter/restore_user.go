@@ -0,0 +1,26 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/service"
+)
+
+// RestoreUserAdapter encapsulate process for restore user in adapter
+type RestoreUserAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewRestoreUserAdapter build an adapter for restore user
+func NewRestoreUserAdapter(
+	service service.ServiceInterface,
+) RestoreUserAdapter {
+	return RestoreUserAdapter{
+		service: service,
+	}
+}
+
+func (r RestoreUserAdapter) Execute(ctx context.Context, userID int) error {
+	err := r.service.RestoreUser(ctx, userID)
+
+	return err
+}
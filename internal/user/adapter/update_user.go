@@ -0,0 +1,28 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service"
+)
+
+// UpdateUserAdapter encapsulate process for update user in adapter
+type UpdateUserAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewUpdateUserAdapter build an adapter for update user
+func NewUpdateUserAdapter(
+	service service.ServiceInterface,
+) UpdateUserAdapter {
+	return UpdateUserAdapter{
+		service: service,
+	}
+}
+
+func (r UpdateUserAdapter) Execute(ctx context.Context, params *public.UpdateUserParams) (*model.User, error) {
+	result, err := r.service.UpdateUser(ctx, params)
+
+	return result, err
+}
@@ -0,0 +1,28 @@
+package adapter
+
+import (
+	"context"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/service"
+	"io"
+)
+
+// UploadAvatarAdapter encapsulate process for upload avatar in adapter
+type UploadAvatarAdapter struct {
+	service service.ServiceInterface
+}
+
+// NewUploadAvatarAdapter build an adapter for upload avatar
+func NewUploadAvatarAdapter(
+	service service.ServiceInterface,
+) UploadAvatarAdapter {
+	return UploadAvatarAdapter{
+		service: service,
+	}
+}
+
+func (r UploadAvatarAdapter) Execute(ctx context.Context, userID int, avatar io.Reader) (*model.User, error) {
+	result, err := r.service.UploadAvatar(ctx, userID, avatar)
+
+	return result, err
+}
@@ -0,0 +1,125 @@
+package user
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/pkg/apperror"
+)
+
+// custom attribute types
+const (
+	AttributeTypeString  = "string"
+	AttributeTypeNumber  = "number"
+	AttributeTypeBoolean = "boolean"
+)
+
+// AttributeDefinition describes a custom attribute allowed on the user profile
+type AttributeDefinition struct {
+	Type      string   `json:"type"`
+	Required  bool     `json:"required"`
+	MaxLength int      `json:"maxLength"`
+	Enum      []string `json:"enum"`
+}
+
+// AttributeSchema lists the custom attributes allowed on the user profile by name
+type AttributeSchema map[string]AttributeDefinition
+
+// AttributeError represents a custom attribute that does not match the schema
+type AttributeError struct {
+	Attribute string
+	Reason    string
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("invalid custom attribute %q: %s", e.Attribute, e.Reason)
+}
+
+// ParseAttributeSchema parses the json encoded attribute schema
+func ParseAttributeSchema(schemaJSON string) (AttributeSchema, error) {
+	schema := AttributeSchema{}
+	if schemaJSON == "" {
+		return schema, nil
+	}
+
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse attribute schema: %v", err)
+	}
+
+	for name, definition := range schema {
+		switch definition.Type {
+		case AttributeTypeString, AttributeTypeNumber, AttributeTypeBoolean:
+		default:
+			return nil, fmt.Errorf("attribute %q has unknown type %q", name, definition.Type)
+		}
+	}
+
+	return schema, nil
+}
+
+// Validate checks the attributes against the schema, unknown attributes are rejected
+func (s AttributeSchema) Validate(attributes model.Attributes) error {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		definition, ok := s[name]
+		if !ok {
+			return newAttributeError(name, "unknown attribute")
+		}
+
+		if err := definition.validate(attributes[name]); err != nil {
+			return newAttributeError(name, err.Error())
+		}
+	}
+
+	for name, definition := range s {
+		if _, ok := attributes[name]; definition.Required && !ok {
+			return newAttributeError(name, "attribute is required")
+		}
+	}
+
+	return nil
+}
+
+// newAttributeError creates an invalid argument error caused by an AttributeError
+func newAttributeError(attribute, reason string) error {
+	err := &AttributeError{Attribute: attribute, Reason: reason}
+	return apperror.Wrap(err, apperror.CodeInvalidArgument, "invalid custom attributes")
+}
+
+func (d AttributeDefinition) validate(value interface{}) error {
+	switch d.Type {
+	case AttributeTypeString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("must be a string")
+		}
+		if d.MaxLength > 0 && len([]rune(str)) > d.MaxLength {
+			return fmt.Errorf("must be at most %d characters", d.MaxLength)
+		}
+		if len(d.Enum) > 0 {
+			for _, allowed := range d.Enum {
+				if str == allowed {
+					return nil
+				}
+			}
+			return fmt.Errorf("must be one of %v", d.Enum)
+		}
+	case AttributeTypeNumber:
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("must be a number")
+		}
+	case AttributeTypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("must be a boolean")
+		}
+	}
+
+	return nil
+}
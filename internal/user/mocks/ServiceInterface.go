@@ -5,10 +5,11 @@ package mocks
 import (
 	context "context"
 	model "home24-technical-test/internal/user/model"
+	public "home24-technical-test/internal/user/public"
+	io "io"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
-
-	public "home24-technical-test/internal/user/public"
 )
 
 // ServiceInterface is an autogenerated mock type for the ServiceInterface type
@@ -58,6 +59,29 @@ func (_m *ServiceInterface) DeleteUser(ctx context.Context, userID int) error {
 	return r0
 }
 
+// GetAvatar provides a mock function with given fields: ctx, userID
+func (_m *ServiceInterface) GetAvatar(ctx context.Context, userID int) (io.ReadCloser, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 io.ReadCloser
+	if rf, ok := ret.Get(0).(func(context.Context, int) io.ReadCloser); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(io.ReadCloser)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUser provides a mock function with given fields: ctx, userID
 func (_m *ServiceInterface) GetUser(ctx context.Context, userID int) (*model.User, error) {
 	ret := _m.Called(ctx, userID)
@@ -104,16 +128,62 @@ func (_m *ServiceInterface) GetUserByEmail(ctx context.Context, email string) (*
 	return r0, r1
 }
 
+// ListDeletedUsers provides a mock function with given fields: ctx, params
+func (_m *ServiceInterface) ListDeletedUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	ret := _m.Called(ctx, params)
+
+	var r0 *public.UsersPage
+	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) *public.UsersPage); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*public.UsersPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *public.FindAllUsersParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPurgeableUsers provides a mock function with given fields: ctx, deletedBefore
+func (_m *ServiceInterface) ListPurgeableUsers(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []int); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListUsers provides a mock function with given fields: ctx, params
-func (_m *ServiceInterface) ListUsers(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error) {
+func (_m *ServiceInterface) ListUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
 	ret := _m.Called(ctx, params)
 
-	var r0 []*model.User
-	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) []*model.User); ok {
+	var r0 *public.UsersPage
+	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) *public.UsersPage); ok {
 		r0 = rf(ctx, params)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*model.User)
+			r0 = ret.Get(0).(*public.UsersPage)
 		}
 	}
 
@@ -127,6 +197,43 @@ func (_m *ServiceInterface) ListUsers(ctx context.Context, params *public.FindAl
 	return r0, r1
 }
 
+// PurgeDeletedUsers provides a mock function with given fields: ctx, deletedBefore
+func (_m *ServiceInterface) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []int); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreUser provides a mock function with given fields: ctx, userID
+func (_m *ServiceInterface) RestoreUser(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateUser provides a mock function with given fields: ctx, params
 func (_m *ServiceInterface) UpdateUser(ctx context.Context, params *public.UpdateUserParams) (*model.User, error) {
 	ret := _m.Called(ctx, params)
@@ -149,3 +256,26 @@ func (_m *ServiceInterface) UpdateUser(ctx context.Context, params *public.Updat
 
 	return r0, r1
 }
+
+// UploadAvatar provides a mock function with given fields: ctx, userID, avatar
+func (_m *ServiceInterface) UploadAvatar(ctx context.Context, userID int, avatar io.Reader) (*model.User, error) {
+	ret := _m.Called(ctx, userID, avatar)
+
+	var r0 *model.User
+	if rf, ok := ret.Get(0).(func(context.Context, int, io.Reader) *model.User); ok {
+		r0 = rf(ctx, userID, avatar)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int, io.Reader) error); ok {
+		r1 = rf(ctx, userID, avatar)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -15,17 +15,26 @@ type SessionServiceInterface struct {
 }
 
 // CreateSession provides a mock function with given fields: ctx, _a1, loginToken
-func (_m *SessionServiceInterface) CreateSession(ctx context.Context, _a1 *model.User, loginToken string) error {
+func (_m *SessionServiceInterface) CreateSession(ctx context.Context, _a1 *model.User, loginToken string) (*model.Session, error) {
 	ret := _m.Called(ctx, _a1, loginToken)
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *model.User, string) error); ok {
+	var r0 *model.Session
+	if rf, ok := ret.Get(0).(func(context.Context, *model.User, string) *model.Session); ok {
 		r0 = rf(ctx, _a1, loginToken)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.Session)
+		}
 	}
 
-	return r0
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.User, string) error); ok {
+		r1 = rf(ctx, _a1, loginToken)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // DeleteSession provides a mock function with given fields: ctx, userID
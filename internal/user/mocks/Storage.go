@@ -5,10 +5,10 @@ package mocks
 import (
 	context "context"
 	model "home24-technical-test/internal/user/model"
+	public "home24-technical-test/internal/user/public"
+	time "time"
 
 	mock "github.com/stretchr/testify/mock"
-
-	public "home24-technical-test/internal/user/public"
 )
 
 // Storage is an autogenerated mock type for the Storage type
@@ -31,15 +31,38 @@ func (_m *Storage) Delete(ctx context.Context, userID int) error {
 }
 
 // FindAll provides a mock function with given fields: ctx, params
-func (_m *Storage) FindAll(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error) {
+func (_m *Storage) FindAll(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
 	ret := _m.Called(ctx, params)
 
-	var r0 []*model.User
-	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) []*model.User); ok {
+	var r0 *public.UsersPage
+	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) *public.UsersPage); ok {
 		r0 = rf(ctx, params)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]*model.User)
+			r0 = ret.Get(0).(*public.UsersPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *public.FindAllUsersParams) error); ok {
+		r1 = rf(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindAllDeleted provides a mock function with given fields: ctx, params
+func (_m *Storage) FindAllDeleted(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	ret := _m.Called(ctx, params)
+
+	var r0 *public.UsersPage
+	if rf, ok := ret.Get(0).(func(context.Context, *public.FindAllUsersParams) *public.UsersPage); ok {
+		r0 = rf(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*public.UsersPage)
 		}
 	}
 
@@ -99,6 +122,29 @@ func (_m *Storage) FindByID(ctx context.Context, userID int) (*model.User, error
 	return r0, r1
 }
 
+// FindDeletedBefore provides a mock function with given fields: ctx, deletedBefore
+func (_m *Storage) FindDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]*model.User, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	var r0 []*model.User
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*model.User); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Insert provides a mock function with given fields: ctx, _a1
 func (_m *Storage) Insert(ctx context.Context, _a1 *model.User) error {
 	ret := _m.Called(ctx, _a1)
@@ -113,6 +159,43 @@ func (_m *Storage) Insert(ctx context.Context, _a1 *model.User) error {
 	return r0
 }
 
+// Purge provides a mock function with given fields: ctx, deletedBefore
+func (_m *Storage) Purge(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	ret := _m.Called(ctx, deletedBefore)
+
+	var r0 []int
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []int); ok {
+		r0 = rf(ctx, deletedBefore)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, deletedBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: ctx, userID
+func (_m *Storage) Restore(ctx context.Context, userID int) error {
+	ret := _m.Called(ctx, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) error); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Update provides a mock function with given fields: ctx, updatedUser
 func (_m *Storage) Update(ctx context.Context, updatedUser *model.User) error {
 	ret := _m.Called(ctx, updatedUser)
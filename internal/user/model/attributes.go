@@ -0,0 +1,36 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Attributes represents the custom attributes bag of the user, stored as json
+type Attributes map[string]interface{}
+
+// Value implements the driver.Valuer interface
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements the sql.Scanner interface
+func (a *Attributes) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*a = Attributes{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("unsupported attributes type: %T", src)
+	}
+
+	return json.Unmarshal(b, a)
+}
@@ -10,3 +10,39 @@ type Session struct {
 	Info      map[string]interface{}
 	User      *User
 }
+
+// UserID returns the id of the user owning the session, kept in the session info
+func (s *Session) UserID() int {
+	// the id is a float64 once the session went through json
+	switch userID := s.Info["UserID"].(type) {
+	case int:
+		return userID
+	case float64:
+		return int(userID)
+	}
+	return 0
+}
+
+// CSRFToken returns the csrf token of the session, kept in the session info
+func (s *Session) CSRFToken() string {
+	csrfToken, _ := s.Info["CSRFToken"].(string)
+	return csrfToken
+}
+
+// Roles returns the roles granted to the session, kept in the session info
+func (s *Session) Roles() []string {
+	// the roles are a []interface{} once the session went through json
+	switch roles := s.Info["Roles"].(type) {
+	case []string:
+		return roles
+	case []interface{}:
+		names := make([]string, 0, len(roles))
+		for _, role := range roles {
+			if name, ok := role.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
@@ -16,4 +16,13 @@ type User struct {
 	UpdatedBy int        `json:"-" db:"updatedBy"`
 	DeletedAt *time.Time `json:"-" db:"deletedAt"`
 	DeletedBy *int       `json:"-" db:"deletedBy"`
+	Version   int        `json:"-" db:"version"`
+
+	PhoneNumber           string     `json:"phoneNumber" db:"phoneNumber"`
+	Locale                string     `json:"locale" db:"locale"`
+	Timezone              string     `json:"timezone" db:"timezone"`
+	MarketingEmailConsent bool       `json:"marketingEmailConsent" db:"marketingEmailConsent"`
+	MarketingSMSConsent   bool       `json:"marketingSmsConsent" db:"marketingSmsConsent"`
+	Avatar                string     `json:"-" db:"avatar"`
+	CustomAttributes      Attributes `json:"customAttributes" db:"customAttributes"`
 }
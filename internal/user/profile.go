@@ -0,0 +1,157 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/blobstore"
+)
+
+// Profile errors
+var (
+	ErrInvalidPhoneNumber    = apperror.New(apperror.CodeInvalidArgument, "phone number must be in E.164 format")
+	ErrInvalidLocale         = apperror.New(apperror.CodeInvalidArgument, "invalid locale")
+	ErrInvalidTimezone       = apperror.New(apperror.CodeInvalidArgument, "invalid timezone")
+	ErrAvatarNotFound        = apperror.New(apperror.CodeNotFound, "avatar is not found")
+	ErrAvatarTooLarge        = apperror.New(apperror.CodePayloadTooLarge, "avatar is too large")
+	ErrUnsupportedAvatarType = apperror.New(apperror.CodeUnsupportedMediaType, "avatar must be a jpeg, png, gif or webp image")
+)
+
+var (
+	// phone numbers are stored in E.164 format
+	phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	// locales are language tags such as "de" or "de-DE"
+	localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+)
+
+// avatarTypes maps the accepted avatar content types to their file extension
+var avatarTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// applyProfile validates & applies the profile fields given in the params onto the user
+func (s *Service) applyProfile(u *model.User, params *public.UpdateUserParams) error {
+	if params.PhoneNumber != nil {
+		if *params.PhoneNumber != "" && !phoneNumberPattern.MatchString(*params.PhoneNumber) {
+			return ErrInvalidPhoneNumber
+		}
+		u.PhoneNumber = *params.PhoneNumber
+	}
+	if params.Locale != nil {
+		if *params.Locale != "" && !localePattern.MatchString(*params.Locale) {
+			return ErrInvalidLocale
+		}
+		u.Locale = *params.Locale
+	}
+	if params.Timezone != nil {
+		if _, err := time.LoadLocation(*params.Timezone); *params.Timezone != "" && err != nil {
+			return ErrInvalidTimezone
+		}
+		u.Timezone = *params.Timezone
+	}
+	if params.MarketingEmailConsent != nil {
+		u.MarketingEmailConsent = *params.MarketingEmailConsent
+	}
+	if params.MarketingSMSConsent != nil {
+		u.MarketingSMSConsent = *params.MarketingSMSConsent
+	}
+	if params.CustomAttributes != nil {
+		if err := s.attributeSchema.Validate(params.CustomAttributes); err != nil {
+			return err
+		}
+		u.CustomAttributes = params.CustomAttributes
+	}
+
+	return nil
+}
+
+// UploadAvatar stores the avatar image & replaces the user's previous one
+func (s *Service) UploadAvatar(ctx context.Context, userID int, avatar io.Reader) (*model.User, error) {
+	u, err := s.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := ioutil.ReadAll(io.LimitReader(avatar, s.avatarMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > s.avatarMaxSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	ext, ok := avatarTypes[http.DetectContentType(content)]
+	if !ok {
+		return nil, ErrUnsupportedAvatarType
+	}
+
+	key, err := avatarKey(userID, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.avatarStore.Put(ctx, key, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	previousAvatar := u.Avatar
+	u.Avatar = key
+	err = s.repository.Update(ctx, u)
+	if err != nil {
+		s.avatarStore.Delete(ctx, key)
+		return nil, err
+	}
+
+	if previousAvatar != "" {
+		if err := s.avatarStore.Delete(ctx, previousAvatar); err != nil {
+			log.Printf("failed to delete previous avatar %s: %v", previousAvatar, err)
+		}
+	}
+
+	return u, nil
+}
+
+// GetAvatar opens the user's avatar image
+func (s *Service) GetAvatar(ctx context.Context, userID int) (io.ReadCloser, error) {
+	u, err := s.repository.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Avatar == "" {
+		return nil, ErrAvatarNotFound
+	}
+
+	avatar, err := s.avatarStore.Get(ctx, u.Avatar)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, ErrAvatarNotFound
+	}
+
+	return avatar, err
+}
+
+func avatarKey(userID int, ext string) (string, error) {
+	buff := make([]byte, 8)
+	_, err := rand.Read(buff)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("avatars/%d/%x%s", userID, buff, ext), nil
+}
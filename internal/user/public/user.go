@@ -1,48 +1,132 @@
 package public
 
-import "home24-technical-test/internal/user/model"
+import (
+	"fmt"
+	"time"
+
+	"home24-technical-test/internal/user/model"
+)
 
 // CreateUserParams represents object to create user
 type CreateUserParams struct {
-	Name     string `json:"name" validate:"required"`
-	Email    string `json:"email" validate:"required"`
-	Address  string `json:"address" validate:"required"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Address  string `json:"address" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
 }
 
-//FindAllUsersParams params for find all
+// Sort fields & orders for listing users
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByEmail     = "email"
+	SortByCreatedAt = "createdAt"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// FindAllUsersParams params for find all
 type FindAllUsersParams struct {
-	Page   int    `json:"page"`
-	Search string `json:"search"`
-	Limit  int    `json:"limit"`
-	Email  string `json:"email"`
-	Name   string `json:"name"`
+	Search      string     `json:"search"`
+	Email       string     `json:"email"`
+	Name        string     `json:"name"`
+	CreatedFrom *time.Time `json:"createdFrom"`
+	CreatedTo   *time.Time `json:"createdTo"`
+	SortBy      string     `json:"sortBy"`
+	SortOrder   string     `json:"sortOrder"`
+	Limit       int        `json:"limit"`
+	Cursor      string     `json:"cursor"`
+}
+
+// UsersPage represents a single page of the users listing
+type UsersPage struct {
+	Users      []*model.User
+	Total      int
+	NextCursor string
+	PrevCursor string
+}
+
+// UserResponse represents a user on the users listing
+type UserResponse struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Address   string     `json:"address"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy *int       `json:"deletedBy,omitempty"`
+
+	PhoneNumber           string           `json:"phoneNumber"`
+	Locale                string           `json:"locale"`
+	Timezone              string           `json:"timezone"`
+	MarketingEmailConsent bool             `json:"marketingEmailConsent"`
+	MarketingSMSConsent   bool             `json:"marketingSmsConsent"`
+	AvatarURL             string           `json:"avatarUrl,omitempty"`
+	CustomAttributes      model.Attributes `json:"customAttributes"`
+}
+
+// AvatarURL returns the url serving the avatar of the user
+func AvatarURL(userID int) string {
+	return fmt.Sprintf("/v1/users/%d/avatar", userID)
+}
+
+// ListUsersResponse represents the response of the users listing
+type ListUsersResponse struct {
+	Users      []*UserResponse `json:"users"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+	PrevCursor string          `json:"prevCursor,omitempty"`
 }
 
 // LoginResponse represents the response of login function
 type LoginResponse struct {
-	SessionID string      `json:"sessionId"`
+	SessionID string `json:"sessionId"`
+	// CSRFToken must be sent in the X-CSRF-Token header of the state changing requests authorized by the session cookie
+	CSRFToken string      `json:"csrfToken"`
+	ExpiredAt time.Time   `json:"expiredAt"`
 	User      *model.User `json:"user"`
 }
 
 // LoginParams represent the http request data for login user
 type LoginParams struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=100"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 // ChangePasswordParams represent the http request data for change password
 // swagger:model
 type ChangePasswordParams struct {
-	OldPassword string `json:"oldPassword"`
-	NewPassword string `json:"newPassword"`
+	OldPassword string `json:"oldPassword" validate:"required,max=72"`
+	NewPassword string `json:"newPassword" validate:"required,min=8,max=72"`
 }
 
 // UpdateUserParams represent the http request data for update user
 type UpdateUserParams struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Address  string `json:"address"`
-	Password string `json:"password"`
+	ID      int    `json:"-"`
+	Name    string `json:"name" validate:"max=100"`
+	Email   string `json:"email" validate:"email,max=100"`
+	Address string `json:"address" validate:"max=255"`
+	// profile fields are left untouched when nil
+	PhoneNumber           *string          `json:"phoneNumber" validate:"max=20"`
+	Locale                *string          `json:"locale" validate:"max=35"`
+	Timezone              *string          `json:"timezone" validate:"max=64"`
+	MarketingEmailConsent *bool            `json:"marketingEmailConsent"`
+	MarketingSMSConsent   *bool            `json:"marketingSmsConsent"`
+	CustomAttributes      model.Attributes `json:"customAttributes"`
+
+	// Version is the user version the update is based on, taken from the If-Match header.
+	// Zero skips the version check
+	Version int `json:"-"`
+}
+
+// IntrospectionResponse represents the RFC 7662 introspection of a session token, inactive tokens only have Active set
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	UserID    int      `json:"user_id,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Purger periodically hard deletes users that stayed soft deleted longer than the retention period
+type Purger struct {
+	service   ServiceInterface
+	retention time.Duration
+	interval  time.Duration
+}
+
+// Run purges the deleted users on every interval until the context is done
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.service.PurgeDeletedUsers(ctx, p.retention); err != nil {
+				log.Printf("failed to purge deleted users: %v", err)
+			}
+		}
+	}
+}
+
+// NewPurger creates a new deleted users purger
+func NewPurger(
+	service ServiceInterface,
+	retention time.Duration,
+	interval time.Duration,
+) *Purger {
+	return &Purger{
+		service:   service,
+		retention: retention,
+		interval:  interval,
+	}
+}
@@ -3,7 +3,12 @@ package service
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"home24-technical-test/internal/user"
 	"home24-technical-test/internal/user/model"
@@ -14,7 +19,7 @@ import (
 
 // ServiceInterface represents the user application service interface
 type ServiceInterface interface {
-	ListUsers(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error)
+	ListUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
 	GetUser(ctx context.Context, userID int) (*model.User, error)
 	CreateUser(ctx context.Context, params *public.CreateUserParams) error
 	UpdateUser(ctx context.Context, params *public.UpdateUserParams) (*model.User, error)
@@ -23,6 +28,12 @@ type ServiceInterface interface {
 	Login(ctx context.Context, params *public.LoginParams) (*public.LoginResponse, error)
 	Logout(ctx context.Context, token string) error
 	GetLoginSession(ctx context.Context, token string) (*model.Session, error)
+	ListDeletedUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
+	RestoreUser(ctx context.Context, userID int) error
+	PurgeDeletedUsers(ctx context.Context, retention time.Duration) error
+	UploadAvatar(ctx context.Context, userID int, avatar io.Reader) (*model.User, error)
+	GetAvatar(ctx context.Context, userID int) (io.ReadCloser, error)
+	IntrospectToken(ctx context.Context, token string) (*public.IntrospectionResponse, error)
 }
 
 // Service is the domain logic implementation of user Service interface
@@ -42,13 +53,13 @@ func (s *Service) GetLoginSession(ctx context.Context, token string) (*model.Ses
 }
 
 // ListUsers is listing all Users
-func (s *Service) ListUsers(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error) {
-	users, err := s.userService.ListUsers(ctx, params)
+func (s *Service) ListUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	page, err := s.userService.ListUsers(ctx, params)
 	if err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	return page, nil
 }
 
 // GetUser get user by its id
@@ -81,7 +92,7 @@ func (s *Service) ChangePassword(ctx context.Context, userID int, oldPassword, n
 	return s.userService.ChangePassword(ctx, userID, oldPassword, newPassword)
 }
 
-//DeleteUser deleting user and its session
+// DeleteUser deleting user and its session
 func (s *Service) DeleteUser(ctx context.Context, userID int) error {
 	err := s.userService.DeleteUser(ctx, userID)
 	if err != nil {
@@ -96,6 +107,65 @@ func (s *Service) DeleteUser(ctx context.Context, userID int) error {
 	return nil
 }
 
+// ListDeletedUsers is listing all soft deleted users
+func (s *Service) ListDeletedUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	page, err := s.userService.ListDeletedUsers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// RestoreUser restores a soft deleted user
+func (s *Service) RestoreUser(ctx context.Context, userID int) error {
+	return s.userService.RestoreUser(ctx, userID)
+}
+
+// PurgeDeletedUsers permanently removes users deleted longer than the retention ago and their sessions.
+// The sessions go first, a user is only purged once its sessions are gone so a failure is retried on the next purge.
+// The users deleted in the meantime aren't purged yet, they were deleted after deletedBefore
+func (s *Service) PurgeDeletedUsers(ctx context.Context, retention time.Duration) error {
+	deletedBefore := time.Now().Add(-retention)
+	userIDs, err := s.userService.ListPurgeableUsers(ctx, deletedBefore)
+	if err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		err = s.userSessionService.DeleteSession(ctx, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.userService.PurgeDeletedUsers(ctx, deletedBefore)
+	return err
+}
+
+// UploadAvatar stores the user's avatar image
+func (s *Service) UploadAvatar(ctx context.Context, userID int, avatar io.Reader) (*model.User, error) {
+	updatedUser, err := s.userService.UploadAvatar(ctx, userID, avatar)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userSessionService.UpdateSession(ctx, updatedUser)
+	if err != nil {
+		return nil, err
+	}
+
+	return updatedUser, nil
+}
+
+// GetAvatar opens the user's avatar image
+func (s *Service) GetAvatar(ctx context.Context, userID int) (io.ReadCloser, error) {
+	return s.userService.GetAvatar(ctx, userID)
+}
+
 // CreateUser creates a new user
 func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParams) error {
 	return s.userService.CreateUser(ctx, params)
@@ -104,12 +174,15 @@ func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParam
 // Login gets the user logged in the system
 func (s *Service) Login(ctx context.Context, params *public.LoginParams) (*public.LoginResponse, error) {
 	loggedUser, err := s.userService.GetUserByEmail(ctx, params.Email)
+	if errors.Is(err, user.ErrNotFound) {
+		return nil, user.ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(loggedUser.Password), []byte(params.Password)); err != nil {
-		return nil, user.ErrWrongPassword
+		return nil, user.ErrInvalidCredentials
 	}
 
 	loginToken, errGenerateToken := generateToken()
@@ -117,17 +190,46 @@ func (s *Service) Login(ctx context.Context, params *public.LoginParams) (*publi
 		return nil, errGenerateToken
 	}
 
-	err = s.userSessionService.CreateSession(ctx, loggedUser, loginToken)
+	session, err := s.userSessionService.CreateSession(ctx, loggedUser, loginToken)
 	if err != nil {
 		return nil, err
 	}
 
 	return &public.LoginResponse{
-		SessionID: loginToken,
+		SessionID: session.ID,
+		CSRFToken: session.CSRFToken(),
+		ExpiredAt: session.ExpiredAt,
 		User:      loggedUser,
 	}, nil
 }
 
+// IntrospectToken describes the session token for the other services, unknown & expired tokens are inactive
+func (s *Service) IntrospectToken(ctx context.Context, token string) (*public.IntrospectionResponse, error) {
+	session, err := s.userSessionService.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || !session.ExpiredAt.After(time.Now()) {
+		return &public.IntrospectionResponse{Active: false}, nil
+	}
+
+	userID := session.UserID()
+	roles := session.Roles()
+	if roles == nil {
+		// the sessions created before the roles were kept have the default roles
+		roles = user.LoginSessionRoles
+	}
+	return &public.IntrospectionResponse{
+		Active:    true,
+		TokenType: session.Type,
+		Sub:       strconv.Itoa(userID),
+		UserID:    userID,
+		Exp:       session.ExpiredAt.Unix(),
+		Scope:     strings.Join(user.LoginSessionScopes, " "),
+		Roles:     roles,
+	}, nil
+}
+
 // Logout gets the user logged out from system
 func (s *Service) Logout(ctx context.Context, token string) error {
 	return s.userSessionService.RemoveSession(ctx, token)
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/mocks"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/storage/memory"
+	"home24-technical-test/pkg/blobstore"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_PurgeDeletedUsers(t *testing.T) {
+	var calls []string
+	users := &mocks.ServiceInterface{}
+	users.On("ListPurgeableUsers", mock.Anything, mock.Anything).Return([]int{1, 2}, nil)
+	users.On("PurgeDeletedUsers", mock.Anything, mock.Anything).Return([]int{1, 2}, nil).
+		Run(func(args mock.Arguments) { calls = append(calls, "purge") })
+	sessions := &mocks.SessionServiceInterface{}
+	sessions.On("DeleteSession", mock.Anything, mock.Anything).Return(nil).
+		Run(func(args mock.Arguments) { calls = append(calls, "sessions") })
+
+	if err := NewService(users, sessions).PurgeDeletedUsers(context.Background(), time.Hour); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+
+	if len(calls) != 3 || calls[2] != "purge" {
+		t.Errorf("expected the sessions of both users to be deleted before the purge, got %v", calls)
+	}
+	sessions.AssertCalled(t, "DeleteSession", mock.Anything, 1)
+	sessions.AssertCalled(t, "DeleteSession", mock.Anything, 2)
+	deletedBefore := users.Calls[0].Arguments.Get(1).(time.Time)
+	if purgedBefore := users.Calls[1].Arguments.Get(1).(time.Time); !purgedBefore.Equal(deletedBefore) {
+		t.Errorf("expected the purge of the listed users, listed before %v, purged before %v", deletedBefore, purgedBefore)
+	}
+}
+
+func TestService_PurgeDeletedUsers_SessionsFailure(t *testing.T) {
+	users := &mocks.ServiceInterface{}
+	users.On("ListPurgeableUsers", mock.Anything, mock.Anything).Return([]int{1}, nil)
+	sessions := &mocks.SessionServiceInterface{}
+	sessionsErr := errors.New("session storage is down")
+	sessions.On("DeleteSession", mock.Anything, 1).Return(sessionsErr)
+
+	if err := NewService(users, sessions).PurgeDeletedUsers(context.Background(), time.Hour); err != sessionsErr {
+		t.Fatalf("expected the sessions error, got %v", err)
+	}
+
+	// the user is kept for the next purge to retry
+	users.AssertNotCalled(t, "PurgeDeletedUsers", mock.Anything, mock.Anything)
+}
+
+func TestService_PurgeDeletedUsers_Avatars(t *testing.T) {
+	ctx := context.Background()
+	avatars := blobstore.NewLocalStore(t.TempDir())
+	storage := memory.NewMemoryStorage()
+	sessions := &mocks.SessionServiceInterface{}
+	sessions.On("DeleteSession", mock.Anything, mock.Anything).Return(nil)
+	svc := NewService(user.NewService(storage, avatars, user.AttributeSchema{}, 1<<20), sessions)
+
+	// insertWithAvatar inserts a user whose avatar is stored under the key
+	insertWithAvatar := func(email, key string) *model.User {
+		if err := avatars.Put(ctx, key, strings.NewReader("avatar")); err != nil {
+			t.Fatalf("failed to put the avatar: %v", err)
+		}
+		u := &model.User{Email: email, Avatar: key}
+		if err := storage.Insert(ctx, u); err != nil {
+			t.Fatalf("failed to insert the user: %v", err)
+		}
+		return u
+	}
+	purged := insertWithAvatar("purged@home24.com", "avatars/purged.png")
+	insertWithAvatar("kept@home24.com", "avatars/kept.png")
+
+	if err := storage.Delete(ctx, purged.ID); err != nil {
+		t.Fatalf("failed to delete the user: %v", err)
+	}
+	if err := svc.PurgeDeletedUsers(ctx, 0); err != nil {
+		t.Fatalf("failed to purge: %v", err)
+	}
+
+	if _, err := avatars.Get(ctx, "avatars/purged.png"); err != blobstore.ErrNotFound {
+		t.Errorf("expected the avatar of the purged user to be deleted, got %v", err)
+	}
+	kept, err := avatars.Get(ctx, "avatars/kept.png")
+	if err != nil {
+		t.Fatalf("expected the avatar of the active user to be kept, got %v", err)
+	}
+	kept.Close()
+}
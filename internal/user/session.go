@@ -2,6 +2,8 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"home24-technical-test/internal/user/model"
 	"time"
 )
@@ -11,6 +13,19 @@ const (
 	LoginSessionType = "login"
 )
 
+// SessionTTL is how long a login session lasts
+const SessionTTL = 48 * time.Hour
+
+// scopes & roles granted to login sessions, there is no role management yet so every user gets the same,
+// the users of the admin emails are also granted the admin role
+var (
+	LoginSessionScopes = []string{"users:read", "users:write"}
+	LoginSessionRoles  = []string{"user"}
+)
+
+// AdminRole lets the session manage the other users
+const AdminRole = "admin"
+
 // SessionStorage represents the session storage interface
 type SessionStorage interface {
 	FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error)
@@ -26,7 +41,7 @@ type SessionServiceInterface interface {
 	GetSession(ctx context.Context, token string) (*model.Session, error)
 	RemoveSession(ctx context.Context, token string) error
 	ExtendingSessionTimeout(ctx context.Context, token string) (*model.Session, error)
-	CreateSession(ctx context.Context, user *model.User, loginToken string) error
+	CreateSession(ctx context.Context, user *model.User, loginToken string) (*model.Session, error)
 	UpdateSession(ctx context.Context, user *model.User) error
 	DeleteSession(ctx context.Context, userID int) error
 }
@@ -34,6 +49,8 @@ type SessionServiceInterface interface {
 // SessionService is the domain logic implementation of user session service interface
 type SessionService struct {
 	sessionStorage SessionStorage
+	// adminEmails are the normalized emails of the users granted the admin role
+	adminEmails map[string]bool
 }
 
 // GetSession get session by token given
@@ -63,14 +80,14 @@ func (s *SessionService) RemoveSession(ctx context.Context, token string) error
 	return nil
 }
 
-// ExtendingSessionTimeout extends session expiration date for 2d
+// ExtendingSessionTimeout extends session expiration date for the session ttl
 func (s *SessionService) ExtendingSessionTimeout(ctx context.Context, token string) (*model.Session, error) {
 	session, err := s.sessionStorage.FindByTokenAndType(ctx, token, LoginSessionType)
 	if err != nil {
 		return nil, err
 	}
 
-	session.ExpiredAt = time.Now().Add(48 * time.Hour)
+	session.ExpiredAt = time.Now().Add(SessionTTL)
 	if err := s.sessionStorage.Update(ctx, session); err != nil {
 		return nil, err
 	}
@@ -98,35 +115,67 @@ func (s *SessionService) DeleteSession(ctx context.Context, userID int) error {
 	return nil
 }
 
-// CreateSession creates user session
-func (s *SessionService) CreateSession(ctx context.Context, user *model.User, loginToken string) error {
+// CreateSession creates user session with its csrf token
+func (s *SessionService) CreateSession(ctx context.Context, user *model.User, loginToken string) (*model.Session, error) {
 	err := s.sessionStorage.DeleteByUserID(ctx, user.ID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	csrfToken, err := generateCSRFToken()
+	if err != nil {
+		return nil, err
 	}
 
 	session := &model.Session{
 		ID:        loginToken,
 		Type:      LoginSessionType,
-		ExpiredAt: time.Now().Add(48 * time.Hour),
+		ExpiredAt: time.Now().Add(SessionTTL),
 		Info: map[string]interface{}{
-			"UserID": user.ID,
+			"UserID":    user.ID,
+			"CSRFToken": csrfToken,
+			"Roles":     s.roles(user),
 		},
 		User: user,
 	}
 
 	if err := s.sessionStorage.Insert(ctx, session); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return session, nil
+}
+
+// roles returns the roles granted to the login sessions of the user
+func (s *SessionService) roles(user *model.User) []string {
+	roles := append([]string{}, LoginSessionRoles...)
+	if s.adminEmails[NormalizeEmail(user.Email)] {
+		roles = append(roles, AdminRole)
+	}
+	return roles
 }
 
-// NewSessionService creates a new user session service
+// generateCSRFToken generates the token the browsers must send back on the state changing requests of cookie sessions
+func generateCSRFToken() (string, error) {
+	buff := make([]byte, 32)
+	if _, err := rand.Read(buff); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buff), nil
+}
+
+// NewSessionService creates a new user session service, the users of the admin emails get the admin role
 func NewSessionService(
 	sessionStorage SessionStorage,
+	adminEmails []string,
 ) *SessionService {
+	admins := make(map[string]bool, len(adminEmails))
+	for _, email := range adminEmails {
+		admins[NormalizeEmail(email)] = true
+	}
+
 	return &SessionService{
 		sessionStorage: sessionStorage,
+		adminEmails:    admins,
 	}
 }
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+)
+
+// entry is a cached session lookup, session is nil when the session doesn't exist
+type entry struct {
+	session   *model.Session
+	expiresAt time.Time
+}
+
+// flight counts the lookups of a key in flight, generation is bumped when the key is invalidated meanwhile
+type flight struct {
+	lookups    int
+	generation uint64
+}
+
+// SessionStorage caches the session lookups of another session storage in process for a short time.
+// Writes through this storage invalidate the cache, writes made elsewhere (e.g. by another instance) must be reported with Revoke
+type SessionStorage struct {
+	storage user.SessionStorage
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]entry
+	lastSweep time.Time
+	// inflight are the lookups reading the storage, a lookup that raced an invalidation isn't cached
+	inflight map[string]*flight
+	// userGeneration is bumped by the invalidations of a user, the user of a lookup in flight isn't known yet
+	userGeneration uint64
+}
+
+// FindByTokenAndType finds a session by its token & type, from the cache when it is fresh enough
+func (s *SessionStorage) FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error) {
+	key := cacheKey(sessType, token)
+	now := time.Now()
+
+	s.mu.Lock()
+	e, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && now.Before(e.expiresAt) {
+		return copySession(e.session), nil
+	}
+
+	s.mu.Lock()
+	f, ok := s.inflight[key]
+	if !ok {
+		f = &flight{}
+		s.inflight[key] = f
+	}
+	f.lookups++
+	generation, userGeneration := f.generation, s.userGeneration
+	s.mu.Unlock()
+
+	session, err := s.storage.FindByTokenAndType(ctx, token, sessType)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f.lookups--
+	if f.lookups == 0 {
+		delete(s.inflight, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the session read before a Delete or a Revoke must not be cached after it
+	if f.generation != generation || s.userGeneration != userGeneration {
+		return session, nil
+	}
+
+	expiresAt := now.Add(s.ttl)
+	if session != nil && session.ExpiredAt.Before(expiresAt) {
+		expiresAt = session.ExpiredAt
+	}
+
+	s.sweep(now)
+	s.entries[key] = entry{session: copySession(session), expiresAt: expiresAt}
+
+	return session, nil
+}
+
+// Insert inserts a new session
+func (s *SessionStorage) Insert(ctx context.Context, session *model.Session) error {
+	s.invalidate(cacheKey(session.Type, session.ID))
+	return s.storage.Insert(ctx, session)
+}
+
+// Update updates the user session
+func (s *SessionStorage) Update(ctx context.Context, session *model.Session) error {
+	defer s.invalidate(cacheKey(session.Type, session.ID))
+	return s.storage.Update(ctx, session)
+}
+
+// Delete deletes the session from storage
+func (s *SessionStorage) Delete(ctx context.Context, token string, sessType string) error {
+	defer s.invalidate(cacheKey(sessType, token))
+	return s.storage.Delete(ctx, token, sessType)
+}
+
+// UpdateByUserID updates the user session based on user id
+func (s *SessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
+	defer s.invalidateUser(session.User.ID)
+	return s.storage.UpdateByUserID(ctx, session)
+}
+
+// DeleteByUserID deletes the sessions of the user
+func (s *SessionStorage) DeleteByUserID(ctx context.Context, userID int) error {
+	defer s.invalidateUser(userID)
+	return s.storage.DeleteByUserID(ctx, userID)
+}
+
+// Revoke drops the cached login session of the token, for sessions deleted outside of this storage
+func (s *SessionStorage) Revoke(token string) {
+	s.invalidate(cacheKey(user.LoginSessionType, token))
+}
+
+func (s *SessionStorage) invalidate(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	if f, ok := s.inflight[key]; ok {
+		f.generation++
+	}
+}
+
+func (s *SessionStorage) invalidateUser(userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.userGeneration++
+
+	for key, e := range s.entries {
+		if e.session != nil && e.session.UserID() == userID {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// sweep drops the expired entries once per ttl, the caller must hold the lock
+func (s *SessionStorage) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	s.lastSweep = now
+
+	for key, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func cacheKey(sessType, token string) string {
+	return fmt.Sprintf("%s:%s", sessType, token)
+}
+
+// copySession copies the session, its info & its user so the callers can't modify the cached session
+func copySession(session *model.Session) *model.Session {
+	if session == nil {
+		return nil
+	}
+
+	c := *session
+	if session.Info != nil {
+		c.Info = make(map[string]interface{}, len(session.Info))
+		for key, value := range session.Info {
+			c.Info[key] = value
+		}
+	}
+	if session.User != nil {
+		u := *session.User
+		c.User = &u
+	}
+	return &c
+}
+
+// NewSessionStorage creates a session storage caching the lookups of storage for ttl
+func NewSessionStorage(storage user.SessionStorage, ttl time.Duration) *SessionStorage {
+	return &SessionStorage{
+		storage:  storage,
+		ttl:      ttl,
+		entries:  make(map[string]entry),
+		inflight: make(map[string]*flight),
+	}
+}
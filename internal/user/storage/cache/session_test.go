@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/storage/memory"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+func TestSessionStorage(t *testing.T) {
+	storagetest.RunSessionStorage(t, func(t *testing.T) user.SessionStorage {
+		return NewSessionStorage(memory.NewSessionStorage(), time.Minute)
+	})
+}
+
+// pausedSessionStorage holds the lookups once they read the storage, until they are released
+type pausedSessionStorage struct {
+	*memory.SessionStorage
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s *pausedSessionStorage) FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error) {
+	session, err := s.SessionStorage.FindByTokenAndType(ctx, token, sessType)
+	s.read <- struct{}{}
+	<-s.release
+	return session, err
+}
+
+func TestSessionStorage_InvalidatedDuringLookup(t *testing.T) {
+	invalidations := []struct {
+		name       string
+		invalidate func(s *SessionStorage, backing user.SessionStorage, session *model.Session) error
+	}{
+		{"delete", func(s *SessionStorage, backing user.SessionStorage, session *model.Session) error {
+			return s.Delete(context.Background(), session.ID, session.Type)
+		}},
+		{"delete by user", func(s *SessionStorage, backing user.SessionStorage, session *model.Session) error {
+			return s.DeleteByUserID(context.Background(), session.UserID())
+		}},
+		{"revoke", func(s *SessionStorage, backing user.SessionStorage, session *model.Session) error {
+			// deleted by another instance
+			if err := backing.Delete(context.Background(), session.ID, session.Type); err != nil {
+				return err
+			}
+			s.Revoke(session.ID)
+			return nil
+		}},
+	}
+
+	for _, tt := range invalidations {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backing := &pausedSessionStorage{
+				SessionStorage: memory.NewSessionStorage(),
+				read:           make(chan struct{}),
+				release:        make(chan struct{}),
+			}
+			s := NewSessionStorage(backing, time.Minute)
+
+			session := &model.Session{
+				ID:        "token",
+				Type:      user.LoginSessionType,
+				ExpiredAt: time.Now().Add(time.Hour),
+				Info:      map[string]interface{}{"UserID": 1},
+				User:      &model.User{ID: 1},
+			}
+			if err := backing.Insert(ctx, session); err != nil {
+				t.Fatalf("failed to insert the session: %v", err)
+			}
+
+			// the lookup reads the session, the session is deleted before the lookup caches it
+			found := make(chan *model.Session, 1)
+			go func() {
+				session, _ := s.FindByTokenAndType(ctx, session.ID, session.Type)
+				found <- session
+			}()
+			<-backing.read
+			if err := tt.invalidate(s, backing.SessionStorage, session); err != nil {
+				t.Fatalf("failed to invalidate the session: %v", err)
+			}
+			close(backing.release)
+			if session := <-found; session == nil {
+				t.Fatal("expected the lookup to return the session it read")
+			}
+
+			// the deleted session is read from the storage again
+			go func() { <-backing.read }()
+			if found, err := s.FindByTokenAndType(ctx, session.ID, session.Type); err != nil || found != nil {
+				t.Errorf("expected the invalidated session not to be cached, got %+v, %v", found, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+)
+
+// sweepInterval is how often the expired sessions are dropped
+const sweepInterval = time.Minute
+
+// SessionStorage implements the session storage in memory, the sessions expire at their ExpiredAt like the Redis keys
+type SessionStorage struct {
+	mu        sync.Mutex
+	sessions  map[string]*model.Session
+	lastSweep time.Time
+}
+
+// FindByTokenAndType finds a session by its token & type, nil when it doesn't exist or expired
+func (ss *SessionStorage) FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	session, ok := ss.sessions[sessionKey(sessType, token)]
+	if !ok || !session.ExpiredAt.After(time.Now()) {
+		return nil, nil
+	}
+
+	return copySession(session), nil
+}
+
+// Insert inserts a new session, a session already expired is not kept
+func (ss *SessionStorage) Insert(ctx context.Context, session *model.Session) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	now := time.Now()
+	ss.sweep(now)
+
+	key := sessionKey(session.Type, session.ID)
+	if !session.ExpiredAt.After(now) {
+		delete(ss.sessions, key)
+		return nil
+	}
+	ss.sessions[key] = copySession(session)
+
+	return nil
+}
+
+// Update updates the expiry & the user of the session
+func (ss *SessionStorage) Update(ctx context.Context, session *model.Session) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	key := sessionKey(session.Type, session.ID)
+	current, ok := ss.sessions[key]
+	if !ok || !current.ExpiredAt.After(time.Now()) {
+		return user.ErrNotFound
+	}
+
+	updated := copySession(current)
+	updated.ExpiredAt = session.ExpiredAt
+	updated.User = copySessionUser(session.User)
+	if !updated.ExpiredAt.After(time.Now()) {
+		delete(ss.sessions, key)
+		return nil
+	}
+	ss.sessions[key] = updated
+
+	return nil
+}
+
+// Delete deletes the session from storage
+func (ss *SessionStorage) Delete(ctx context.Context, token string, sessType string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	delete(ss.sessions, sessionKey(sessType, token))
+	return nil
+}
+
+// UpdateByUserID updates the user of the login sessions of session.User
+func (ss *SessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for key, current := range ss.sessions {
+		if current.Type == user.LoginSessionType && current.UserID() == session.User.ID {
+			updated := copySession(current)
+			updated.User = copySessionUser(session.User)
+			ss.sessions[key] = updated
+		}
+	}
+
+	return nil
+}
+
+// DeleteByUserID delete session user
+func (ss *SessionStorage) DeleteByUserID(ctx context.Context, userID int) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for key, session := range ss.sessions {
+		if session.Type == user.LoginSessionType && session.UserID() == userID {
+			delete(ss.sessions, key)
+		}
+	}
+
+	return nil
+}
+
+// sweep drops the expired sessions once per sweep interval, the caller must hold the lock
+func (ss *SessionStorage) sweep(now time.Time) {
+	if now.Sub(ss.lastSweep) < sweepInterval {
+		return
+	}
+	ss.lastSweep = now
+
+	for key, session := range ss.sessions {
+		if !session.ExpiredAt.After(now) {
+			delete(ss.sessions, key)
+		}
+	}
+}
+
+func sessionKey(sessType, token string) string {
+	return fmt.Sprintf("%s:%s", sessType, token)
+}
+
+// copySession copies the session so the callers can't modify the stored one
+func copySession(session *model.Session) *model.Session {
+	c := *session
+	if session.Info != nil {
+		c.Info = make(map[string]interface{}, len(session.Info))
+		for key, value := range session.Info {
+			c.Info[key] = value
+		}
+	}
+	c.User = copySessionUser(session.User)
+	return &c
+}
+
+// copySessionUser copies the user of the session, if any
+func copySessionUser(u *model.User) *model.User {
+	if u == nil {
+		return nil
+	}
+	return copyUser(u, false)
+}
+
+// NewSessionStorage creates a new in memory session storage
+func NewSessionStorage() *SessionStorage {
+	return &SessionStorage{
+		sessions: make(map[string]*model.Session),
+	}
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+func TestSessionStorage(t *testing.T) {
+	storagetest.RunSessionStorage(t, func(t *testing.T) user.SessionStorage {
+		return NewSessionStorage()
+	})
+}
@@ -0,0 +1,444 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/pagination"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// MemoryStorage implements the user repository in memory, it behaves as the Postgres storage
+// (soft delete, unique active emails, versioned updates, keyset pagination) without a database
+type MemoryStorage struct {
+	mu     sync.RWMutex
+	users  map[int]*model.User
+	lastID int
+}
+
+// FindByID get user by userId
+func (s *MemoryStorage) FindByID(ctx context.Context, userID int) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.DeletedAt != nil {
+		return nil, data.ErrNotFound
+	}
+
+	return copyUser(u, false), nil
+}
+
+// FindByEmail get user by email
+func (s *MemoryStorage) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.findActiveByEmail(email)
+	if u == nil {
+		return nil, data.ErrNotFound
+	}
+
+	return copyUser(u, false), nil
+}
+
+// Insert inserts an user
+func (s *MemoryStorage) Insert(ctx context.Context, singleUser *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.findActiveByEmail(singleUser.Email) != nil {
+		return user.ErrEmailAlreadyExists
+	}
+
+	now := timestamp()
+	s.lastID++
+
+	u := copyUser(singleUser, false)
+	u.ID = s.lastID
+	u.Email = strings.ToLower(u.Email)
+	u.CreatedAt = now
+	u.UpdatedAt = now
+	u.Version = 1
+	if u.CustomAttributes == nil {
+		u.CustomAttributes = model.Attributes{}
+	}
+	s.users[u.ID] = u
+
+	*singleUser = *copyUser(u, false)
+	return nil
+}
+
+// Delete soft deletes user data
+func (s *MemoryStorage) Delete(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.DeletedAt != nil {
+		return data.ErrNotFound
+	}
+
+	now := timestamp()
+	deletedBy := appcontext.UserID(ctx)
+	u.DeletedAt = &now
+	u.DeletedBy = &deletedBy
+
+	return nil
+}
+
+// Restore reverts the soft delete of user data
+func (s *MemoryStorage) Restore(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.DeletedAt == nil {
+		return data.ErrNotFound
+	}
+	if s.findActiveByEmail(u.Email) != nil {
+		return user.ErrEmailAlreadyExists
+	}
+
+	u.DeletedAt = nil
+	u.DeletedBy = nil
+	u.UpdatedAt = timestamp()
+	u.UpdatedBy = appcontext.UserID(ctx)
+
+	return nil
+}
+
+// FindDeletedBefore finds the users soft deleted before the given time, only their id & avatar are read
+func (s *MemoryStorage) FindDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := []*model.User{}
+	for id, u := range s.users {
+		if u.DeletedAt != nil && u.DeletedAt.Before(deletedBefore) {
+			users = append(users, &model.User{ID: id, Avatar: u.Avatar})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+// Purge hard deletes users soft deleted before the given time and returns their ids
+func (s *MemoryStorage) Purge(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userIDs := []int{}
+	for id, u := range s.users {
+		if u.DeletedAt != nil && u.DeletedAt.Before(deletedBefore) {
+			delete(s.users, id)
+			userIDs = append(userIDs, id)
+		}
+	}
+	sort.Ints(userIDs)
+
+	return userIDs, nil
+}
+
+// Update updates user data when its version is still the same as the given user's version,
+// the version is incremented on every update
+func (s *MemoryStorage) Update(ctx context.Context, updatedUser *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[updatedUser.ID]
+	if !ok || u.DeletedAt != nil {
+		return data.ErrNotFound
+	}
+	if u.Version != updatedUser.Version {
+		return user.ErrVersionConflict
+	}
+	if other := s.findActiveByEmail(updatedUser.Email); other != nil && other.ID != u.ID {
+		return user.ErrEmailAlreadyExists
+	}
+
+	updated := copyUser(updatedUser, false)
+	updated.Email = strings.ToLower(updated.Email)
+	updated.CreatedBy = u.CreatedBy
+	updated.CreatedAt = u.CreatedAt
+	updated.UpdatedAt = timestamp()
+	updated.UpdatedBy = appcontext.UserID(ctx)
+	updated.DeletedAt = nil
+	updated.DeletedBy = nil
+	updated.Version = u.Version + 1
+	if updated.CustomAttributes == nil {
+		updated.CustomAttributes = model.Attributes{}
+	}
+	s.users[u.ID] = updated
+
+	*updatedUser = *copyUser(updated, false)
+	return nil
+}
+
+// FindAll finds a page of active users
+func (s *MemoryStorage) FindAll(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(params, false)
+}
+
+// FindAllDeleted finds a page of soft deleted users
+func (s *MemoryStorage) FindAllDeleted(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(params, true)
+}
+
+func (s *MemoryStorage) findAll(params *public.FindAllUsersParams, deleted bool) (*public.UsersPage, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = public.SortByID
+	}
+	if sortBy != public.SortByID && sortBy != public.SortByName && sortBy != public.SortByEmail && sortBy != public.SortByCreatedAt {
+		return nil, user.ErrInvalidSort
+	}
+
+	descending := params.SortOrder != public.SortAsc
+	if params.SortOrder != "" && params.SortOrder != public.SortAsc && params.SortOrder != public.SortDesc {
+		return nil, user.ErrInvalidSort
+	}
+	sortOrder := public.SortDesc
+	if !descending {
+		sortOrder = public.SortAsc
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	var cursor *pagination.Cursor
+	if params.Cursor != "" {
+		var err error
+		cursor, err = pagination.DecodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder {
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	s.mu.RLock()
+	matching := []*model.User{}
+	for _, u := range s.users {
+		if (u.DeletedAt != nil) == deleted && matches(u, params) {
+			matching = append(matching, copyUser(u, true))
+		}
+	}
+	s.mu.RUnlock()
+
+	// paging backward scans in the opposite order, the result is reversed afterward
+	backward := cursor != nil && cursor.Backward
+	scanDescending := descending != backward
+	sort.Slice(matching, func(i, j int) bool {
+		c := compare(sortBy, matching[i], matching[j])
+		if scanDescending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	users := []*model.User{}
+	for _, u := range matching {
+		if cursor != nil {
+			c := compareCursor(sortBy, u, cursor)
+			if (scanDescending && c >= 0) || (!scanDescending && c <= 0) {
+				continue
+			}
+		}
+		users = append(users, u)
+		if len(users) > limit {
+			break
+		}
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	page := &public.UsersPage{
+		Users: users,
+		Total: len(matching),
+	}
+	if len(users) > 0 {
+		if backward || hasMore {
+			page.NextCursor = newCursor(sortBy, sortOrder, users[len(users)-1], false).Encode()
+		}
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			page.PrevCursor = newCursor(sortBy, sortOrder, users[0], true).Encode()
+		}
+	}
+
+	return page, nil
+}
+
+// findActiveByEmail finds the active user of the email case-insensitively, the caller must hold the lock
+func (s *MemoryStorage) findActiveByEmail(email string) *model.User {
+	for _, u := range s.users {
+		if u.DeletedAt == nil && strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+// matches tells whether the user matches the filters of the listing
+func matches(u *model.User, params *public.FindAllUsersParams) bool {
+	if params.Email != "" && !containsFold(u.Email, params.Email) {
+		return false
+	}
+	if params.Name != "" && !containsFold(u.Name, params.Name) {
+		return false
+	}
+	if params.Search != "" && !containsFold(u.Name, params.Search) && !containsFold(u.Email, params.Search) && !containsFold(u.Address, params.Search) {
+		return false
+	}
+	if params.CreatedFrom != nil && u.CreatedAt.Before(*params.CreatedFrom) {
+		return false
+	}
+	if params.CreatedTo != nil && u.CreatedAt.After(*params.CreatedTo) {
+		return false
+	}
+	return true
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// compare orders the users by the sort field then by id
+func compare(sortBy string, a, b *model.User) int {
+	var c int
+	switch sortBy {
+	case public.SortByName:
+		c = strings.Compare(a.Name, b.Name)
+	case public.SortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	case public.SortByCreatedAt:
+		c = compareTime(a.CreatedAt, b.CreatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return compareInt(a.ID, b.ID)
+}
+
+// compareCursor orders the user against the position of the cursor
+func compareCursor(sortBy string, u *model.User, cursor *pagination.Cursor) int {
+	var c int
+	switch sortBy {
+	case public.SortByName:
+		c = strings.Compare(u.Name, cursor.Value)
+	case public.SortByEmail:
+		c = strings.Compare(u.Email, cursor.Value)
+	case public.SortByCreatedAt:
+		value, err := time.Parse(time.RFC3339Nano, cursor.Value)
+		if err != nil {
+			// same as a cast failing in the database, nothing is after an invalid position
+			return 0
+		}
+		c = compareTime(u.CreatedAt, value)
+	}
+	if c != 0 {
+		return c
+	}
+	return compareInt(u.ID, cursor.ID)
+}
+
+func compareTime(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+	return 0
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func newCursor(sortBy, sortOrder string, u *model.User, backward bool) *pagination.Cursor {
+	cursor := &pagination.Cursor{
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+		ID:        u.ID,
+		Backward:  backward,
+	}
+
+	switch sortBy {
+	case public.SortByName:
+		cursor.Value = u.Name
+	case public.SortByEmail:
+		cursor.Value = u.Email
+	case public.SortByCreatedAt:
+		cursor.Value = u.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+// timestamp is the current time with the microsecond precision of the database timestamps
+func timestamp() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// copyUser copies the user so the callers can't modify the stored one,
+// the deletion fields are only selected by the listings
+func copyUser(u *model.User, withDeletion bool) *model.User {
+	c := *u
+	if u.CustomAttributes != nil {
+		c.CustomAttributes = make(model.Attributes, len(u.CustomAttributes))
+		for key, value := range u.CustomAttributes {
+			c.CustomAttributes[key] = value
+		}
+	}
+
+	c.DeletedAt, c.DeletedBy = nil, nil
+	if withDeletion && u.DeletedAt != nil {
+		deletedAt, deletedBy := *u.DeletedAt, *u.DeletedBy
+		c.DeletedAt, c.DeletedBy = &deletedAt, &deletedBy
+	}
+
+	return &c
+}
+
+// NewMemoryStorage creates new in memory user repository service
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users: make(map[int]*model.User),
+	}
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	storagetest.RunUserStorage(t, func(t *testing.T) user.Storage {
+		return NewMemoryStorage()
+	})
+}
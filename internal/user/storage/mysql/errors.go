@@ -0,0 +1,28 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+
+	"home24-technical-test/internal/user"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	duplicateEntryNumber = 1062
+	primaryKey           = "PRIMARY"
+)
+
+// mapError translates the mysql errors into the user domain errors
+func mapError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// the email index is the only other unique key, the key is only named in the message & not by every server
+		if mysqlErr.Number == duplicateEntryNumber && !strings.Contains(mysqlErr.Message, primaryKey) {
+			return user.ErrEmailAlreadyExists
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"os"
+	"testing"
+
+	"home24-technical-test/config"
+	"home24-technical-test/database"
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+// newTestStorage connects to the database from TEST_MYSQL_CONNECTION_STRING, the test is skipped without it
+func newTestStorage(t *testing.T) *MySQLStorage {
+	connectionString := os.Getenv("TEST_MYSQL_CONNECTION_STRING")
+	if connectionString == "" {
+		t.Skip("TEST_MYSQL_CONNECTION_STRING is not set")
+	}
+
+	if err := database.MigrateUp(&config.Config{DBConnectionString: connectionString}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db, err := database.Open(connectionString)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storagetest.CheckConnections(t, db)
+
+	return NewMySQLStorage(db)
+}
+
+func TestMySQLStorage(t *testing.T) {
+	storagetest.RunUserStorage(t, func(t *testing.T) user.Storage {
+		return newTestStorage(t)
+	})
+}
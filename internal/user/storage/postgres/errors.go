@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"errors"
+
+	"home24-technical-test/internal/user"
+
+	"github.com/lib/pq"
+)
+
+const (
+	uniqueViolationCode = "23505"
+	emailUniqueIndex    = "user_email_lower_key"
+)
+
+// mapError translates the postgres errors into the user domain errors
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Code == uniqueViolationCode && pqErr.Constraint == emailUniqueIndex {
+			return user.ErrEmailAlreadyExists
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// SessionReaper periodically deletes the expired rows of the session table
+type SessionReaper struct {
+	storage  *SessionStorage
+	interval time.Duration
+}
+
+// Run deletes the expired sessions on every interval until the context is done
+func (r *SessionReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.storage.DeleteExpired(ctx); err != nil {
+				log.Printf("failed to delete expired sessions: %v", err)
+			}
+		}
+	}
+}
+
+// NewSessionReaper creates a new expired sessions reaper
+func NewSessionReaper(
+	storage *SessionStorage,
+	interval time.Duration,
+) *SessionReaper {
+	return &SessionReaper{
+		storage:  storage,
+		interval: interval,
+	}
+}
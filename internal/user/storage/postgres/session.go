@@ -0,0 +1,239 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/pkg/data"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SessionStorage implements the session storage on the "session" table. The info & the user are kept as json,
+// like the redis sessions, and the expired rows are hidden until the reaper deletes them
+type SessionStorage struct {
+	db *sqlx.DB
+}
+
+// sessionRow is a row of the session table
+type sessionRow struct {
+	Token     string    `db:"token"`
+	Type      string    `db:"type"`
+	ExpiredAt time.Time `db:"expiredAt"`
+	Info      []byte    `db:"info"`
+	User      []byte    `db:"user"`
+}
+
+// FindByTokenAndType finds a session by its token & type, nil when it doesn't exist or expired
+func (ss *SessionStorage) FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error) {
+	var row sessionRow
+	err := data.Get(ctx, ss.db, &row, `
+	SELECT
+		"token", "type", "expiredAt", "info", "user"
+	FROM
+		"session"
+	WHERE
+		"type" = :type AND "token" = :token AND "expiredAt" > :now`, map[string]interface{}{
+		"type":  sessType,
+		"token": token,
+		"now":   time.Now(),
+	})
+	if errors.Is(err, data.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return row.session()
+}
+
+// Insert inserts a new session or replaces the one of the same token & type, a session already expired is not kept
+func (ss *SessionStorage) Insert(ctx context.Context, session *model.Session) error {
+	if !session.ExpiredAt.After(time.Now()) {
+		return ss.Delete(ctx, session.ID, session.Type)
+	}
+
+	info, err := json.Marshal(session.Info)
+	if err != nil {
+		return err
+	}
+	sessionUser, err := marshalSessionUser(session.User)
+	if err != nil {
+		return err
+	}
+
+	_, err = data.Exec(ctx, ss.db, `
+	INSERT INTO "session"
+		("token", "type", "userId", "expiredAt", "info", "user")
+	VALUES
+		(:token, :type, :userId, :expiredAt, :info, :user)
+	ON CONFLICT ("type", "token") DO UPDATE SET
+		"userId" = EXCLUDED."userId",
+		"expiredAt" = EXCLUDED."expiredAt",
+		"info" = EXCLUDED."info",
+		"user" = EXCLUDED."user"`, map[string]interface{}{
+		"token":     session.ID,
+		"type":      session.Type,
+		"userId":    sessionUserID(session),
+		"expiredAt": session.ExpiredAt,
+		"info":      string(info),
+		"user":      sessionUser,
+	})
+
+	return err
+}
+
+// Update updates the expiry & the user of the session
+func (ss *SessionStorage) Update(ctx context.Context, session *model.Session) error {
+	sessionUser, err := marshalSessionUser(session.User)
+	if err != nil {
+		return err
+	}
+
+	result, err := data.Exec(ctx, ss.db, `
+	UPDATE
+		"session"
+	SET
+		"expiredAt" = :expiredAt,
+		"user" = :user
+	WHERE
+		"type" = :type AND "token" = :token AND "expiredAt" > :now`, map[string]interface{}{
+		"expiredAt": session.ExpiredAt,
+		"user":      sessionUser,
+		"type":      session.Type,
+		"token":     session.ID,
+		"now":       time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return user.ErrNotFound
+	}
+
+	return nil
+}
+
+// Delete deletes the session from storage
+func (ss *SessionStorage) Delete(ctx context.Context, token string, sessType string) error {
+	_, err := data.Exec(ctx, ss.db, `
+	DELETE FROM
+		"session"
+	WHERE
+		"type" = :type AND "token" = :token`, map[string]interface{}{
+		"type":  sessType,
+		"token": token,
+	})
+
+	return err
+}
+
+// UpdateByUserID updates the user of the login sessions of session.User
+func (ss *SessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
+	sessionUser, err := marshalSessionUser(session.User)
+	if err != nil {
+		return err
+	}
+
+	_, err = data.Exec(ctx, ss.db, `
+	UPDATE
+		"session"
+	SET
+		"user" = :user
+	WHERE
+		"type" = :type AND "userId" = :userId AND "expiredAt" > :now`, map[string]interface{}{
+		"user":   sessionUser,
+		"type":   user.LoginSessionType,
+		"userId": session.User.ID,
+		"now":    time.Now(),
+	})
+
+	return err
+}
+
+// DeleteByUserID deletes the login sessions of the user
+func (ss *SessionStorage) DeleteByUserID(ctx context.Context, userID int) error {
+	_, err := data.Exec(ctx, ss.db, `
+	DELETE FROM
+		"session"
+	WHERE
+		"type" = :type AND "userId" = :userId`, map[string]interface{}{
+		"type":   user.LoginSessionType,
+		"userId": userID,
+	})
+
+	return err
+}
+
+// DeleteExpired deletes the expired sessions and returns how many were deleted
+func (ss *SessionStorage) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := data.Exec(ctx, ss.db, `
+	DELETE FROM
+		"session"
+	WHERE
+		"expiredAt" <= :now`, map[string]interface{}{
+		"now": time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// session decodes the row into a session
+func (row *sessionRow) session() (*model.Session, error) {
+	session := &model.Session{
+		ID:        row.Token,
+		Type:      row.Type,
+		ExpiredAt: row.ExpiredAt,
+	}
+
+	if err := json.Unmarshal(row.Info, &session.Info); err != nil {
+		return nil, err
+	}
+	if row.User != nil {
+		if err := json.Unmarshal(row.User, &session.User); err != nil {
+			return nil, err
+		}
+	}
+
+	return session, nil
+}
+
+// sessionUserID is the user id indexing the session, nil when the session has no user
+func sessionUserID(session *model.Session) interface{} {
+	if userID := session.UserID(); userID != 0 {
+		return userID
+	}
+	return nil
+}
+
+// marshalSessionUser encodes the user of the session, nil without user
+func marshalSessionUser(u *model.User) (interface{}, error) {
+	if u == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// NewSessionStorage creates a new postgres session storage
+func NewSessionStorage(db *sqlx.DB) *SessionStorage {
+	return &SessionStorage{
+		db: db,
+	}
+}
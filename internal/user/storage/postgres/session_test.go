@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+func TestSessionStorage(t *testing.T) {
+	storagetest.RunSessionStorage(t, func(t *testing.T) user.SessionStorage {
+		return NewSessionStorage(newTestStorage(t).db)
+	})
+}
+
+func TestSessionStorage_DeleteExpired(t *testing.T) {
+	s := NewSessionStorage(newTestStorage(t).db)
+	ctx := context.Background()
+	token := fmt.Sprintf("reaper-%d", time.Now().UnixNano())
+
+	err := s.Insert(ctx, &model.Session{
+		ID:        token,
+		Type:      user.LoginSessionType,
+		ExpiredAt: time.Now().Add(time.Second),
+		Info:      map[string]interface{}{"UserID": 1},
+	})
+	if err != nil {
+		t.Fatalf("failed to insert session: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := s.DeleteExpired(ctx); err != nil {
+		t.Fatalf("failed to delete expired sessions: %v", err)
+	}
+
+	var count int
+	err = s.db.Get(&count, `SELECT count(*) FROM "session" WHERE "token" = $1`, token)
+	if err != nil {
+		t.Fatalf("failed to count sessions: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected the expired session to be deleted, %d left", count)
+	}
+}
@@ -2,19 +2,26 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"home24-technical-test/internal/user"
 	"home24-technical-test/internal/user/model"
 	"home24-technical-test/internal/user/public"
 
 	"home24-technical-test/pkg/appcontext"
 	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/pagination"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// userColumns lists the columns selected into model.User
+const userColumns = `"id", "name", "email", "address", "password", "createdBy", "createdAt", "updatedAt", "updatedBy", "version",
+		"phoneNumber", "locale", "timezone", "marketingEmailConsent", "marketingSmsConsent", "avatar", "customAttributes"`
+
 // PostgresStorage implements the user repository service interface
 type PostgresStorage struct {
 	db *sqlx.DB
@@ -24,29 +31,19 @@ type PostgresStorage struct {
 func (s *PostgresStorage) FindByID(ctx context.Context, userID int) (*model.User, error) {
 	user := &model.User{}
 
-	rows, err := s.db.NamedQuery(`
+	err := data.Get(ctx, s.db, user, `
 	SELECT 
-		"id", "name", "email", "address", "password", "createdBy" 
+		`+userColumns+`
 	FROM
 		"user"
 	WHERE
-		"id" = :id`, map[string]interface{}{
+		"id" = :id AND "deletedAt" IS NULL`, map[string]interface{}{
 		"id": userID,
 	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, data.ErrNotFound
-		}
 		return nil, err
 	}
 
-	for rows.Next() {
-		err = rows.StructScan(user)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	return user, nil
 }
 
@@ -54,163 +51,376 @@ func (s *PostgresStorage) FindByID(ctx context.Context, userID int) (*model.User
 func (s *PostgresStorage) FindByEmail(ctx context.Context, email string) (*model.User, error) {
 	user := &model.User{}
 
-	rows, err := s.db.NamedQuery(`
+	err := data.Get(ctx, s.db, user, `
 	SELECT 
-		"id", "name", "email", "address", "password", "createdBy" 
+		`+userColumns+`
 	FROM
 		"user"
 	WHERE
-		"email" = :email`, map[string]interface{}{
+		lower("email") = lower(:email) AND "deletedAt" IS NULL`, map[string]interface{}{
 		"email": email,
 	})
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, data.ErrNotFound
-		}
 		return nil, err
 	}
 
-	for rows.Next() {
-		err = rows.StructScan(user)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	return user, nil
 }
 
 // Insert inserts an user
 func (s *PostgresStorage) Insert(ctx context.Context, singleUser *model.User) error {
-	rows, err := s.db.NamedQuery(`
+	err := data.Get(ctx, s.db, singleUser, `
 	INSERT INTO 
-		"user" ("name","email","address","password","createdBy", "createdAt", "updatedAt", "updatedBy")
+		"user" ("name","email","address","password","createdBy", "createdAt", "updatedAt", "updatedBy",
+			"phoneNumber", "locale", "timezone", "marketingEmailConsent", "marketingSmsConsent", "avatar", "customAttributes")
 	VALUES
-		(:name, :email, :address, :password, :createdBy, now(), :createdBy, now())
+		(:name, lower(:email), :address, :password, :createdBy, now(), now(), :updatedBy,
+			:phoneNumber, :locale, :timezone, :marketingEmailConsent, :marketingSmsConsent, :avatar, :customAttributes)
 	RETURNING
-		"id", "name","email","address","password","createdBy", "createdAt", "updatedAt", "updatedBy"`, singleUser)
+		`+userColumns, singleUser)
+
+	return mapError(err)
+}
+
+// Delete soft deletes user data
+func (s *PostgresStorage) Delete(ctx context.Context, userID int) error {
+	result, err := data.Exec(ctx, s.db, `
+	UPDATE "user" 
+	SET
+		"deletedAt" = NOW(),
+		"deletedBy" = :deletedBy
+	WHERE
+		"id" = :id AND "deletedAt" IS NULL`, map[string]interface{}{
+		"id":        userID,
+		"deletedBy": appcontext.UserID(ctx),
+	})
 	if err != nil {
 		return err
 	}
 
-	for rows.Next() {
-		err = rows.StructScan(singleUser)
-		if err != nil {
-			return err
-		}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrNotFound
 	}
 
 	return nil
 }
 
-// Delete user data
-func (s *PostgresStorage) Delete(ctx context.Context, userID int) error {
-	_, err := s.db.Queryx(`
+// Restore reverts the soft delete of user data
+func (s *PostgresStorage) Restore(ctx context.Context, userID int) error {
+	result, err := data.Exec(ctx, s.db, `
 	UPDATE "user" 
 	SET
-		"deletedAt" = NOW(),
-		"deletedBy" = :deletedBy
+		"deletedAt" = NULL,
+		"deletedBy" = NULL,
+		"updatedAt" = NOW(),
+		"updatedBy" = :updatedBy
 	WHERE
-		"id" = ?`, userID)
+		"id" = :id AND "deletedAt" IS NOT NULL`, map[string]interface{}{
+		"id":        userID,
+		"updatedBy": appcontext.UserID(ctx),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if affected == 0 {
+		return data.ErrNotFound
+	}
 
 	return nil
 }
 
-// Update user data
-func (s *PostgresStorage) Update(ctx context.Context, updatedUser *model.User) error {
-	updatedUser.UpdatedAt = time.Now()
-	updatedUser.UpdatedBy = appcontext.UserID(ctx)
+// FindDeletedBefore finds the users soft deleted before the given time, only their id & avatar are read
+func (s *PostgresStorage) FindDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]*model.User, error) {
+	users := []*model.User{}
 
-	rows, err := s.db.NamedQuery(`
+	err := data.Select(ctx, s.db, &users, `
+	SELECT
+		"id", "avatar"
+	FROM
+		"user"
+	WHERE
+		"deletedAt" IS NOT NULL AND "deletedAt" < :deletedBefore
+	ORDER BY
+		"id"`, map[string]interface{}{
+		"deletedBefore": deletedBefore,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Purge hard deletes users soft deleted before the given time and returns their ids
+func (s *PostgresStorage) Purge(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	userIDs := []int{}
+
+	err := data.Select(ctx, s.db, &userIDs, `
+	DELETE FROM
+		"user"
+	WHERE
+		"deletedAt" IS NOT NULL AND "deletedAt" < :deletedBefore
+	RETURNING
+		"id"`, map[string]interface{}{
+		"deletedBefore": deletedBefore,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// Update updates user data when its version is still the same as the given user's version,
+// the version is incremented on every update
+func (s *PostgresStorage) Update(ctx context.Context, updatedUser *model.User) error {
+	err := data.Get(ctx, s.db, updatedUser, `
 	UPDATE "user" 
 	SET
 		"name" = :name,
-		"email" = :email,
-		"address" = "address,
+		"email" = lower(:email),
+		"address" = :address,
 		"password" = :password,
 		"updatedAt" = :updatedAt,
-		"updatedBy" = :updatedBy
+		"updatedBy" = :updatedBy,
+		"phoneNumber" = :phoneNumber,
+		"locale" = :locale,
+		"timezone" = :timezone,
+		"marketingEmailConsent" = :marketingEmailConsent,
+		"marketingSmsConsent" = :marketingSmsConsent,
+		"avatar" = :avatar,
+		"customAttributes" = :customAttributes,
+		"version" = "version" + 1
 	WHERE
-		"id" = :id
+		"id" = :id AND "version" = :version AND "deletedAt" IS NULL
 	RETURNING
-		"id", "name","email","address","password","createdBy", "createdAt", "updatedAt", "updatedBy"`,
+		`+userColumns,
 		map[string]interface{}{
 			"id":        updatedUser.ID,
 			"name":      updatedUser.Name,
 			"email":     updatedUser.Email,
 			"address":   updatedUser.Address,
 			"password":  updatedUser.Password,
-			"updatedAt": updatedUser.UpdatedAt,
-			"updatedBy": updatedUser.UpdatedBy,
-		})
-	if err != nil {
-		return err
-	}
+			"updatedAt": time.Now(),
+			"updatedBy": appcontext.UserID(ctx),
+			"version":   updatedUser.Version,
 
-	for rows.Next() {
-		err = rows.StructScan(updatedUser)
-		if err != nil {
+			"phoneNumber":           updatedUser.PhoneNumber,
+			"locale":                updatedUser.Locale,
+			"timezone":              updatedUser.Timezone,
+			"marketingEmailConsent": updatedUser.MarketingEmailConsent,
+			"marketingSmsConsent":   updatedUser.MarketingSMSConsent,
+			"avatar":                updatedUser.Avatar,
+			"customAttributes":      updatedUser.CustomAttributes,
+		})
+	if errors.Is(err, data.ErrNotFound) {
+		// nothing updated, either the user is gone or it was changed in the meantime
+		if _, err = s.FindByID(ctx, updatedUser.ID); err != nil {
 			return err
 		}
+		return user.ErrVersionConflict
 	}
 
-	return nil
+	return mapError(err)
 }
 
-//FindAll is stand for
-func (s *PostgresStorage) FindAll(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error) {
-	users := []*model.User{}
+// FindAll finds a page of active users
+func (s *PostgresStorage) FindAll(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(ctx, params, `"deletedAt" IS NULL`)
+}
+
+// FindAllDeleted finds a page of soft deleted users
+func (s *PostgresStorage) FindAllDeleted(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(ctx, params, `"deletedAt" IS NOT NULL`)
+}
 
-	where := `"deletedAt" IS NULL`
+// sortColumns maps the sort fields to their column & the type used to cast the cursor value
+var sortColumns = map[string][2]string{
+	public.SortByID:        {`"id"`, ""},
+	public.SortByName:      {`"name"`, "varchar"},
+	public.SortByEmail:     {`"email"`, "varchar"},
+	public.SortByCreatedAt: {`"createdAt"`, "timestamptz"},
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+func (s *PostgresStorage) findAll(ctx context.Context, params *public.FindAllUsersParams, where string) (*public.UsersPage, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = public.SortByID
+	}
+	sortColumn, ok := sortColumns[sortBy]
+	if !ok {
+		return nil, user.ErrInvalidSort
+	}
+
+	descending := params.SortOrder != public.SortAsc
+	if params.SortOrder != "" && params.SortOrder != public.SortAsc && params.SortOrder != public.SortDesc {
+		return nil, user.ErrInvalidSort
+	}
+	sortOrder := public.SortDesc
+	if !descending {
+		sortOrder = public.SortAsc
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	var cursor *pagination.Cursor
+	if params.Cursor != "" {
+		var err error
+		cursor, err = pagination.DecodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder {
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	args := map[string]interface{}{
+		"limit": limit + 1,
+	}
 	if params.Email != "" {
 		where += ` AND "email" ILIKE :email`
+		args["email"] = likePattern(params.Email)
 	}
 	if params.Name != "" {
 		where += ` AND "name" ILIKE :name`
+		args["name"] = likePattern(params.Name)
 	}
 	if params.Search != "" {
-		where += ` AND "name" ILIKE :search`
+		where += ` AND ("name" ILIKE :search OR "email" ILIKE :search OR "address" ILIKE :search)`
+		args["search"] = likePattern(params.Search)
 	}
-	if params.Page != 0 && params.Limit != 0 {
-		where = fmt.Sprintf(`%s ORDER BY "id" DESC LIMIT :limit OFFSET :offset`, where)
-	} else {
-		where = fmt.Sprintf(`%s ORDER BY "id" DESC`, where)
+	if params.CreatedFrom != nil {
+		where += ` AND "createdAt" >= :createdFrom`
+		args["createdFrom"] = *params.CreatedFrom
+	}
+	if params.CreatedTo != nil {
+		where += ` AND "createdAt" <= :createdTo`
+		args["createdTo"] = *params.CreatedTo
 	}
 
-	rows, err := s.db.NamedQuery(fmt.Sprintf(`
+	total, err := s.count(ctx, where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// paging backward scans in the opposite order, the result is reversed afterward
+	backward := cursor != nil && cursor.Backward
+	scanDescending := descending != backward
+	order, operator := "ASC", ">"
+	if scanDescending {
+		order, operator = "DESC", "<"
+	}
+
+	pageWhere := where
+	if cursor != nil {
+		args["cursorID"] = cursor.ID
+		if sortBy == public.SortByID {
+			pageWhere += fmt.Sprintf(` AND "id" %s :cursorID`, operator)
+		} else {
+			args["cursorValue"] = cursor.Value
+			pageWhere += fmt.Sprintf(` AND (%s, "id") %s (CAST(:cursorValue AS %s), :cursorID)`, sortColumn[0], operator, sortColumn[1])
+		}
+	}
+
+	users := []*model.User{}
+	err = data.Select(ctx, s.db, &users, fmt.Sprintf(`
 	SELECT 
-		"id", "name", "email", "address", "password", "createdBy" 
+		`+userColumns+`, "deletedAt", "deletedBy"
 	FROM
 		"user"
 	WHERE
-		%s`, where), map[string]interface{}{
-		"limit":  params.Limit,
-		"email":  params.Email,
-		"name":   params.Name,
-		"search": "%" + params.Search + "%",
-		"offset": ((params.Page - 1) * params.Limit),
-	})
+		%s
+	ORDER BY
+		%s %s, "id" %s
+	LIMIT :limit`, pageWhere, sortColumn[0], order, order), args)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, data.ErrNotFound
-		}
 		return nil, err
 	}
 
-	for rows.Next() {
-		user := &model.User{}
-		err = rows.StructScan(user)
-		if err != nil {
-			return nil, err
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	page := &public.UsersPage{
+		Users: users,
+		Total: total,
+	}
+	if len(users) > 0 {
+		if backward || hasMore {
+			page.NextCursor = newCursor(sortBy, sortOrder, users[len(users)-1], false).Encode()
 		}
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			page.PrevCursor = newCursor(sortBy, sortOrder, users[0], true).Encode()
+		}
+	}
+
+	return page, nil
+}
+
+func (s *PostgresStorage) count(ctx context.Context, where string, args map[string]interface{}) (int, error) {
+	var total int
+	err := data.Get(ctx, s.db, &total, fmt.Sprintf(`
+	SELECT 
+		COUNT(*)
+	FROM
+		"user"
+	WHERE
+		%s`, where), args)
+
+	return total, err
+}
 
-		users = append(users, user)
+func newCursor(sortBy, sortOrder string, u *model.User, backward bool) *pagination.Cursor {
+	cursor := &pagination.Cursor{
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+		ID:        u.ID,
+		Backward:  backward,
 	}
 
-	return users, nil
+	switch sortBy {
+	case public.SortByName:
+		cursor.Value = u.Name
+	case public.SortByEmail:
+		cursor.Value = u.Email
+	case public.SortByCreatedAt:
+		cursor.Value = u.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+// likePattern builds an ILIKE pattern matching the given text anywhere
+func likePattern(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+	return "%" + text + "%"
 }
 
 // NewPostgresStorage creates new user repository service
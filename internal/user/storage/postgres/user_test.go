@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"home24-technical-test/config"
+	"home24-technical-test/database"
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/storage/storagetest"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// newTestStorage connects to the database from TEST_DB_CONNECTION_STRING, the test is skipped without it
+func newTestStorage(t *testing.T) *PostgresStorage {
+	dsn := os.Getenv("TEST_DB_CONNECTION_STRING")
+	if dsn == "" {
+		t.Skip("TEST_DB_CONNECTION_STRING is not set")
+	}
+
+	if err := database.MigrateUp(&config.Config{DBConnectionString: dsn}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storagetest.CheckConnections(t, db)
+
+	return NewPostgresStorage(db)
+}
+
+func insertTestUser(t *testing.T, s *PostgresStorage) *model.User {
+	u := &model.User{
+		Name:     "concurrent",
+		Email:    fmt.Sprintf("concurrent-%d@home24.com", time.Now().UnixNano()),
+		Address:  "Berlin",
+		Password: "secret",
+	}
+	if err := s.Insert(context.Background(), u); err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+
+	return u
+}
+
+func TestPostgresStorage_Update_ConcurrentWritersOnlyOneWins(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	inserted := insertTestUser(t, s)
+
+	const writers = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// every writer edits its own copy read at the same version
+			u := *inserted
+			u.Name = fmt.Sprintf("writer-%d", i)
+			errs <- s.Update(ctx, &u)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded, conflicted int
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, user.ErrVersionConflict):
+			conflicted++
+		default:
+			t.Fatalf("unexpected update error: %v", err)
+		}
+	}
+
+	if succeeded != 1 || conflicted != writers-1 {
+		t.Fatalf("expected 1 successful and %d conflicting updates, got %d and %d", writers-1, succeeded, conflicted)
+	}
+
+	stored, err := s.FindByID(ctx, inserted.ID)
+	if err != nil {
+		t.Fatalf("failed to find user: %v", err)
+	}
+	if stored.Version != inserted.Version+1 {
+		t.Fatalf("expected version %d, got %d", inserted.Version+1, stored.Version)
+	}
+}
+
+func TestPostgresStorage_Update_StaleVersion(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	inserted := insertTestUser(t, s)
+
+	first := *inserted
+	first.Address = "Hamburg"
+	if err := s.Update(ctx, &first); err != nil {
+		t.Fatalf("first update failed: %v", err)
+	}
+
+	stale := *inserted
+	stale.Address = "Munich"
+	if err := s.Update(ctx, &stale); !errors.Is(err, user.ErrVersionConflict) {
+		t.Fatalf("expected ErrVersionConflict, got %v", err)
+	}
+
+	// retrying on top of the latest version succeeds
+	if err := s.Update(ctx, &first); err != nil {
+		t.Fatalf("update on latest version failed: %v", err)
+	}
+}
+
+func TestPostgresStorage(t *testing.T) {
+	storagetest.RunUserStorage(t, func(t *testing.T) user.Storage {
+		return newTestStorage(t)
+	})
+}
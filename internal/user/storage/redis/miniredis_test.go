@@ -0,0 +1,45 @@
+//go:build miniredis
+// +build miniredis
+
+package redis
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// TestMain runs the tests against an in process miniredis instead of the redis of TEST_REDIS_ADDR,
+// it also serves the cluster tests as a single node cluster
+func TestMain(m *testing.M) {
+	server, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+
+	// miniredis only expires the keys when its clock is moved forward, it follows the real time here
+	done := make(chan struct{})
+	go func() {
+		const tick = 10 * time.Millisecond
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				server.FastForward(tick)
+			}
+		}
+	}()
+
+	os.Setenv("TEST_REDIS_ADDR", server.Addr())
+	os.Setenv("TEST_REDIS_CLUSTER_ADDRS", server.Addr())
+	code := m.Run()
+
+	close(done)
+	server.Close()
+	os.Exit(code)
+}
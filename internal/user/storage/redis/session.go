@@ -3,8 +3,8 @@ package redis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"home24-technical-test/internal/user"
@@ -13,15 +13,16 @@ import (
 	"github.com/go-redis/redis"
 )
 
-// SessionStorage represents the implementation of
+// SessionStorage represents the implementation of the session storage on redis, a single node, sentinel or cluster.
+// The tokens of the login sessions of a user are indexed in a set, its key is hash tagged by the user id
 type SessionStorage struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
 }
 
 // FindByTokenAndType finds a session by its token & type
 func (ss *SessionStorage) FindByTokenAndType(ctx context.Context, token string, sessType string) (*model.Session, error) {
 	val, err := ss.redisClient.Get(fmt.Sprintf("%s:%s", sessType, token)).Result()
-	if err == redis.Nil {
+	if errors.Is(err, redis.Nil) {
 		return nil, nil
 	} else if err != nil {
 		return nil, err
@@ -35,29 +36,65 @@ func (ss *SessionStorage) FindByTokenAndType(ctx context.Context, token string,
 	return &session, nil
 }
 
-// Insert inserts a new session
+// Insert inserts a new session, a session already expired is not kept
 func (ss *SessionStorage) Insert(ctx context.Context, session *model.Session) error {
 	sessionBytes, err := json.Marshal(session)
 	if err != nil {
 		return err
 	}
 
-	if err := ss.redisClient.Set(
-		fmt.Sprintf("%s:%s", session.Type, session.ID),
-		sessionBytes,
-		session.ExpiredAt.Sub(time.Now())).Err(); err != nil {
+	key := fmt.Sprintf("%s:%s", session.Type, session.ID)
+	// redis keeps the keys without expiration forever
+	ttl := time.Until(session.ExpiredAt)
+	if ttl <= 0 {
+		return ss.redisClient.Del(key).Err()
+	}
+
+	if err := ss.redisClient.Set(key, sessionBytes, ttl).Err(); err != nil {
+		return err
+	}
+
+	if userID := session.UserID(); session.Type == user.LoginSessionType && userID != 0 {
+		return ss.indexLoginSession(userID, session.ID, ttl)
+	}
+
+	return nil
+}
+
+// indexLoginSession adds the token to the login sessions of the user, the index lives as long as its last session
+func (ss *SessionStorage) indexLoginSession(userID int, token string, ttl time.Duration) error {
+	key := userSessionsKey(userID)
+	if err := ss.redisClient.SAdd(key, token).Err(); err != nil {
+		return err
+	}
+
+	// the index has no expiry (negative ttl) when it was just created
+	current, err := ss.redisClient.PTTL(key).Result()
+	if err != nil {
 		return err
 	}
+	if current < ttl {
+		return ss.redisClient.PExpire(key, ttl).Err()
+	}
 
 	return nil
 }
 
+// userSessionsKey is the key of the set of the login session tokens of the user,
+// the hash tag keeps the keys of a user in the same cluster slot
+func userSessionsKey(userID int) string {
+	return fmt.Sprintf("user:{%d}:sessions", userID)
+}
+
 // Update updates the user session
 func (ss *SessionStorage) Update(ctx context.Context, session *model.Session) error {
 	updatedSession, err := ss.FindByTokenAndType(ctx, session.ID, session.Type)
 	if err != nil {
 		return err
 	}
+	if updatedSession == nil {
+		return user.ErrNotFound
+	}
 
 	updatedSession.ExpiredAt = session.ExpiredAt
 	updatedSession.User = session.User
@@ -70,66 +107,117 @@ func (ss *SessionStorage) Update(ctx context.Context, session *model.Session) er
 	return nil
 }
 
-// Delete deletes the session from storage
+// Delete deletes the session from storage, deleted login sessions are announced on the revoked channel
 func (ss *SessionStorage) Delete(ctx context.Context, token string, sessType string) error {
 	_, err := ss.redisClient.Del(fmt.Sprintf("%s:%s", sessType, token)).Result()
 	if err != nil {
 		return err
 	}
 
+	if sessType == user.LoginSessionType {
+		if err := ss.redisClient.Publish(revokedChannel, token).Err(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// UpdateByUserID  updates the user session based on user id
-func (ss *SessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
-	loginKeys := ss.redisClient.Keys(`*login*`)
+// revokedChannel is the channel the tokens of the deleted login sessions are published on
+const revokedChannel = "session:revoked"
 
-	for _, loginKey := range loginKeys.Val() {
-		splitLoginKey := strings.Split(loginKey, ":")
+// SubscribeRevoked calls revoke with the token of every login session deleted from now on, by any instance.
+// It blocks until ctx is done
+func (ss *SessionStorage) SubscribeRevoked(ctx context.Context, revoke func(token string)) error {
+	pubsub := ss.redisClient.Subscribe(revokedChannel)
+	defer pubsub.Close()
 
-		currentSession, err := ss.FindByTokenAndType(ctx, splitLoginKey[1], user.LoginSessionType)
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			revoke(msg.Payload)
+		}
+	}
+}
+
+// UpdateByUserID updates the user of the login sessions of session.User, the expired tokens are dropped from its index
+func (ss *SessionStorage) UpdateByUserID(ctx context.Context, session *model.Session) error {
+	key := userSessionsKey(session.User.ID)
+	tokens, err := ss.redisClient.SMembers(key).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		currentSession, err := ss.FindByTokenAndType(ctx, token, user.LoginSessionType)
 		if err != nil {
 			return err
 		}
 
-		if currentSession != nil && currentSession.User.ID == session.User.ID {
-			currentSession.User = session.User
-			err = ss.Update(ctx, currentSession)
-			if err != nil {
+		// the deleted sessions stay in the index until now
+		if currentSession == nil || currentSession.UserID() != session.User.ID {
+			if err := ss.redisClient.SRem(key, token).Err(); err != nil {
 				return err
 			}
+			continue
+		}
 
-			session = currentSession
+		currentSession.User = session.User
+		err = ss.Update(ctx, currentSession)
+		if err != nil && !errors.Is(err, user.ErrNotFound) {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// DeleteByUserID delete session user
+// DeleteByUserID deletes the login sessions of the user, only the deleted tokens are dropped from the index
+// so the sessions created meanwhile stay indexed
 func (ss *SessionStorage) DeleteByUserID(ctx context.Context, userID int) error {
-	loginKeys := ss.redisClient.Keys(`*login*`)
-
-	for _, loginKey := range loginKeys.Val() {
-		splitLoginKey := strings.Split(loginKey, ":")
+	key := userSessionsKey(userID)
+	tokens, err := ss.redisClient.SMembers(key).Result()
+	if err != nil {
+		return err
+	}
 
-		session, err := ss.FindByTokenAndType(ctx, splitLoginKey[1], user.LoginSessionType)
+	for _, token := range tokens {
+		session, err := ss.FindByTokenAndType(ctx, token, user.LoginSessionType)
 		if err != nil {
 			return err
 		}
 
-		if session != nil && session.User.ID == userID {
-			err = ss.Delete(ctx, splitLoginKey[1], user.LoginSessionType)
+		if session != nil && session.UserID() == userID {
+			err = ss.Delete(ctx, token, user.LoginSessionType)
 			if err != nil {
 				return err
 			}
 		}
 	}
-	return nil
+
+	if len(tokens) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(tokens))
+	for i, token := range tokens {
+		members[i] = token
+	}
+	return ss.redisClient.SRem(key, members...).Err()
 }
 
 // NewSessionStorage creates a new session storage
-func NewSessionStorage(redisClient *redis.Client) *SessionStorage {
+func NewSessionStorage(redisClient redis.UniversalClient) *SessionStorage {
 	return &SessionStorage{
 		redisClient,
 	}
@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/storage/storagetest"
+
+	"github.com/go-redis/redis"
+)
+
+// newTestStorage connects to the redis at TEST_REDIS_ADDR, the test is skipped without it
+func newTestStorage(t *testing.T) *SessionStorage {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+
+	redisClient := redis.NewClient(&redis.Options{Addr: addr})
+	if err := redisClient.Ping().Err(); err != nil {
+		t.Fatalf("failed to connect to redis: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+
+	return NewSessionStorage(redisClient)
+}
+
+func TestSessionStorage(t *testing.T) {
+	storagetest.RunSessionStorage(t, func(t *testing.T) user.SessionStorage {
+		return newTestStorage(t)
+	})
+}
+
+// newTestClusterStorage connects to the redis cluster at TEST_REDIS_CLUSTER_ADDRS (comma separated), the test is skipped without it
+func newTestClusterStorage(t *testing.T) *SessionStorage {
+	addrs := os.Getenv("TEST_REDIS_CLUSTER_ADDRS")
+	if addrs == "" {
+		t.Skip("TEST_REDIS_CLUSTER_ADDRS is not set")
+	}
+
+	redisClient := redis.NewClusterClient(&redis.ClusterOptions{Addrs: strings.Split(addrs, ",")})
+	if err := redisClient.Ping().Err(); err != nil {
+		t.Fatalf("failed to connect to redis cluster: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+
+	return NewSessionStorage(redisClient)
+}
+
+func TestSessionStorage_Cluster(t *testing.T) {
+	storagetest.RunSessionStorage(t, func(t *testing.T) user.SessionStorage {
+		return newTestClusterStorage(t)
+	})
+}
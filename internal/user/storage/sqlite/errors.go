@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+
+	"home24-technical-test/internal/user"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const emailUniqueIndex = "user_email_lower_key"
+
+// mapError translates the sqlite errors into the user domain errors
+func mapError(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		// sqlite names the index in the message only
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique && strings.Contains(sqliteErr.Error(), emailUniqueIndex) {
+			return user.ErrEmailAlreadyExists
+		}
+	}
+
+	return err
+}
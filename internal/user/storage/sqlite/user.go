@@ -0,0 +1,512 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/data"
+	"home24-technical-test/pkg/pagination"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// userColumns lists the columns selected into model.User
+const userColumns = `"id", "name", "email", "address", "password", "createdBy", "createdAt", "updatedAt", "updatedBy", "version",
+		"phoneNumber", "locale", "timezone", "marketingEmailConsent", "marketingSmsConsent", "avatar", "customAttributes"`
+
+// SQLiteStorage implements the user repository service interface on sqlite.
+// The timestamps are stored as utc text, they sort in time order
+type SQLiteStorage struct {
+	db *sqlx.DB
+}
+
+// FindByID get user by userId
+func (s *SQLiteStorage) FindByID(ctx context.Context, userID int) (*model.User, error) {
+	user := &model.User{}
+
+	err := data.Get(ctx, s.db, user, `
+	SELECT
+		`+userColumns+`
+	FROM
+		"user"
+	WHERE
+		"id" = :id AND "deletedAt" IS NULL`, map[string]interface{}{
+		"id": userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// FindByEmail get user by email
+func (s *SQLiteStorage) FindByEmail(ctx context.Context, email string) (*model.User, error) {
+	user := &model.User{}
+
+	err := data.Get(ctx, s.db, user, `
+	SELECT
+		`+userColumns+`
+	FROM
+		"user"
+	WHERE
+		lower("email") = lower(:email) AND "deletedAt" IS NULL`, map[string]interface{}{
+		"email": email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Insert inserts an user
+func (s *SQLiteStorage) Insert(ctx context.Context, singleUser *model.User) error {
+	now := timestamp()
+
+	result, err := data.Exec(ctx, s.db, `
+	INSERT INTO
+		"user" ("name","email","address","password","createdBy", "createdAt", "updatedAt", "updatedBy",
+			"phoneNumber", "locale", "timezone", "marketingEmailConsent", "marketingSmsConsent", "avatar", "customAttributes")
+	VALUES
+		(:name, lower(:email), :address, :password, :createdBy, :createdAt, :updatedAt, :updatedBy,
+			:phoneNumber, :locale, :timezone, :marketingEmailConsent, :marketingSmsConsent, :avatar, :customAttributes)`,
+		map[string]interface{}{
+			"name":      singleUser.Name,
+			"email":     singleUser.Email,
+			"address":   singleUser.Address,
+			"password":  singleUser.Password,
+			"createdBy": singleUser.CreatedBy,
+			"createdAt": now,
+			"updatedAt": now,
+			"updatedBy": singleUser.UpdatedBy,
+
+			"phoneNumber":           singleUser.PhoneNumber,
+			"locale":                singleUser.Locale,
+			"timezone":              singleUser.Timezone,
+			"marketingEmailConsent": singleUser.MarketingEmailConsent,
+			"marketingSmsConsent":   singleUser.MarketingSMSConsent,
+			"avatar":                singleUser.Avatar,
+			"customAttributes":      singleUser.CustomAttributes,
+		})
+	if err != nil {
+		return mapError(err)
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	// sqlite has no RETURNING, the user is read back
+	inserted, err := s.FindByID(ctx, int(userID))
+	if err != nil {
+		return err
+	}
+	*singleUser = *inserted
+
+	return nil
+}
+
+// Delete soft deletes user data
+func (s *SQLiteStorage) Delete(ctx context.Context, userID int) error {
+	result, err := data.Exec(ctx, s.db, `
+	UPDATE "user"
+	SET
+		"deletedAt" = :deletedAt,
+		"deletedBy" = :deletedBy
+	WHERE
+		"id" = :id AND "deletedAt" IS NULL`, map[string]interface{}{
+		"id":        userID,
+		"deletedAt": timestamp(),
+		"deletedBy": appcontext.UserID(ctx),
+	})
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrNotFound
+	}
+
+	return nil
+}
+
+// Restore reverts the soft delete of user data
+func (s *SQLiteStorage) Restore(ctx context.Context, userID int) error {
+	result, err := data.Exec(ctx, s.db, `
+	UPDATE "user"
+	SET
+		"deletedAt" = NULL,
+		"deletedBy" = NULL,
+		"updatedAt" = :updatedAt,
+		"updatedBy" = :updatedBy
+	WHERE
+		"id" = :id AND "deletedAt" IS NOT NULL`, map[string]interface{}{
+		"id":        userID,
+		"updatedAt": timestamp(),
+		"updatedBy": appcontext.UserID(ctx),
+	})
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindDeletedBefore finds the users soft deleted before the given time, only their id & avatar are read
+func (s *SQLiteStorage) FindDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]*model.User, error) {
+	users := []*model.User{}
+
+	err := data.Select(ctx, s.db, &users, `
+	SELECT
+		"id", "avatar"
+	FROM
+		"user"
+	WHERE
+		"deletedAt" IS NOT NULL AND "deletedAt" < :deletedBefore
+	ORDER BY
+		"id"`, map[string]interface{}{
+		"deletedBefore": deletedBefore.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Purge hard deletes users soft deleted before the given time and returns their ids
+func (s *SQLiteStorage) Purge(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	candidates, err := s.FindDeletedBefore(ctx, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{
+		"deletedBefore": deletedBefore.UTC(),
+	}
+
+	// sqlite has no DELETE RETURNING, the users restored in the meantime are kept & not returned
+	userIDs := []int{}
+	for _, candidate := range candidates {
+		args["id"] = candidate.ID
+		result, err := data.Exec(ctx, s.db, `
+		DELETE FROM
+			"user"
+		WHERE
+			"id" = :id AND "deletedAt" IS NOT NULL AND "deletedAt" < :deletedBefore`, args)
+		if err != nil {
+			return nil, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if affected == 1 {
+			userIDs = append(userIDs, candidate.ID)
+		}
+	}
+
+	return userIDs, nil
+}
+
+// Update updates user data when its version is still the same as the given user's version,
+// the version is incremented on every update
+func (s *SQLiteStorage) Update(ctx context.Context, updatedUser *model.User) error {
+	result, err := data.Exec(ctx, s.db, `
+	UPDATE "user"
+	SET
+		"name" = :name,
+		"email" = lower(:email),
+		"address" = :address,
+		"password" = :password,
+		"updatedAt" = :updatedAt,
+		"updatedBy" = :updatedBy,
+		"phoneNumber" = :phoneNumber,
+		"locale" = :locale,
+		"timezone" = :timezone,
+		"marketingEmailConsent" = :marketingEmailConsent,
+		"marketingSmsConsent" = :marketingSmsConsent,
+		"avatar" = :avatar,
+		"customAttributes" = :customAttributes,
+		"version" = "version" + 1
+	WHERE
+		"id" = :id AND "version" = :version AND "deletedAt" IS NULL`,
+		map[string]interface{}{
+			"id":        updatedUser.ID,
+			"name":      updatedUser.Name,
+			"email":     updatedUser.Email,
+			"address":   updatedUser.Address,
+			"password":  updatedUser.Password,
+			"updatedAt": timestamp(),
+			"updatedBy": appcontext.UserID(ctx),
+			"version":   updatedUser.Version,
+
+			"phoneNumber":           updatedUser.PhoneNumber,
+			"locale":                updatedUser.Locale,
+			"timezone":              updatedUser.Timezone,
+			"marketingEmailConsent": updatedUser.MarketingEmailConsent,
+			"marketingSmsConsent":   updatedUser.MarketingSMSConsent,
+			"avatar":                updatedUser.Avatar,
+			"customAttributes":      updatedUser.CustomAttributes,
+		})
+	if err != nil {
+		return mapError(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// nothing updated, either the user is gone or it was changed in the meantime
+		if _, err = s.FindByID(ctx, updatedUser.ID); err != nil {
+			return err
+		}
+		return user.ErrVersionConflict
+	}
+
+	updated, err := s.FindByID(ctx, updatedUser.ID)
+	if err != nil {
+		return err
+	}
+	*updatedUser = *updated
+
+	return nil
+}
+
+// FindAll finds a page of active users
+func (s *SQLiteStorage) FindAll(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(ctx, params, `"deletedAt" IS NULL`)
+}
+
+// FindAllDeleted finds a page of soft deleted users
+func (s *SQLiteStorage) FindAllDeleted(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	return s.findAll(ctx, params, `"deletedAt" IS NOT NULL`)
+}
+
+// sortColumns maps the sort fields to their column
+var sortColumns = map[string]string{
+	public.SortByID:        `"id"`,
+	public.SortByName:      `"name"`,
+	public.SortByEmail:     `"email"`,
+	public.SortByCreatedAt: `"createdAt"`,
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+func (s *SQLiteStorage) findAll(ctx context.Context, params *public.FindAllUsersParams, where string) (*public.UsersPage, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = public.SortByID
+	}
+	sortColumn, ok := sortColumns[sortBy]
+	if !ok {
+		return nil, user.ErrInvalidSort
+	}
+
+	descending := params.SortOrder != public.SortAsc
+	if params.SortOrder != "" && params.SortOrder != public.SortAsc && params.SortOrder != public.SortDesc {
+		return nil, user.ErrInvalidSort
+	}
+	sortOrder := public.SortDesc
+	if !descending {
+		sortOrder = public.SortAsc
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	var cursor *pagination.Cursor
+	var cursorValue interface{}
+	if params.Cursor != "" {
+		var err error
+		cursor, err = pagination.DecodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder {
+			return nil, pagination.ErrInvalidCursor
+		}
+
+		cursorValue = cursor.Value
+		if sortBy == public.SortByCreatedAt {
+			// compared with the stored text, the time must be formatted the same way
+			createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+			if err != nil {
+				return nil, pagination.ErrInvalidCursor
+			}
+			cursorValue = createdAt.UTC()
+		}
+	}
+
+	args := map[string]interface{}{
+		"limit": limit + 1,
+	}
+	if params.Email != "" {
+		where += ` AND "email" LIKE :email ESCAPE '\'`
+		args["email"] = likePattern(params.Email)
+	}
+	if params.Name != "" {
+		where += ` AND "name" LIKE :name ESCAPE '\'`
+		args["name"] = likePattern(params.Name)
+	}
+	if params.Search != "" {
+		where += ` AND ("name" LIKE :search ESCAPE '\' OR "email" LIKE :search ESCAPE '\' OR "address" LIKE :search ESCAPE '\')`
+		args["search"] = likePattern(params.Search)
+	}
+	if params.CreatedFrom != nil {
+		where += ` AND "createdAt" >= :createdFrom`
+		args["createdFrom"] = params.CreatedFrom.UTC()
+	}
+	if params.CreatedTo != nil {
+		where += ` AND "createdAt" <= :createdTo`
+		args["createdTo"] = params.CreatedTo.UTC()
+	}
+
+	total, err := s.count(ctx, where, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// paging backward scans in the opposite order, the result is reversed afterward
+	backward := cursor != nil && cursor.Backward
+	scanDescending := descending != backward
+	order, operator := "ASC", ">"
+	if scanDescending {
+		order, operator = "DESC", "<"
+	}
+
+	pageWhere := where
+	if cursor != nil {
+		args["cursorID"] = cursor.ID
+		if sortBy == public.SortByID {
+			pageWhere += fmt.Sprintf(` AND "id" %s :cursorID`, operator)
+		} else {
+			args["cursorValue"] = cursorValue
+			pageWhere += fmt.Sprintf(` AND (%s, "id") %s (:cursorValue, :cursorID)`, sortColumn, operator)
+		}
+	}
+
+	users := []*model.User{}
+	err = data.Select(ctx, s.db, &users, fmt.Sprintf(`
+	SELECT
+		`+userColumns+`, "deletedAt", "deletedBy"
+	FROM
+		"user"
+	WHERE
+		%s
+	ORDER BY
+		%s %s, "id" %s
+	LIMIT :limit`, pageWhere, sortColumn, order, order), args)
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+	if backward {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	page := &public.UsersPage{
+		Users: users,
+		Total: total,
+	}
+	if len(users) > 0 {
+		if backward || hasMore {
+			page.NextCursor = newCursor(sortBy, sortOrder, users[len(users)-1], false).Encode()
+		}
+		if (backward && hasMore) || (!backward && cursor != nil) {
+			page.PrevCursor = newCursor(sortBy, sortOrder, users[0], true).Encode()
+		}
+	}
+
+	return page, nil
+}
+
+func (s *SQLiteStorage) count(ctx context.Context, where string, args map[string]interface{}) (int, error) {
+	var total int
+	err := data.Get(ctx, s.db, &total, fmt.Sprintf(`
+	SELECT
+		COUNT(*)
+	FROM
+		"user"
+	WHERE
+		%s`, where), args)
+
+	return total, err
+}
+
+func newCursor(sortBy, sortOrder string, u *model.User, backward bool) *pagination.Cursor {
+	cursor := &pagination.Cursor{
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+		ID:        u.ID,
+		Backward:  backward,
+	}
+
+	switch sortBy {
+	case public.SortByName:
+		cursor.Value = u.Name
+	case public.SortByEmail:
+		cursor.Value = u.Email
+	case public.SortByCreatedAt:
+		cursor.Value = u.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	return cursor
+}
+
+// likePattern builds a LIKE pattern matching the given text anywhere, sqlite's LIKE ignores the ascii case
+func likePattern(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+	return "%" + text + "%"
+}
+
+// timestamp is the current utc time with the microsecond precision of the other databases
+func timestamp() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// NewSQLiteStorage creates new user repository service
+func NewSQLiteStorage(
+	db *sqlx.DB,
+) *SQLiteStorage {
+	return &SQLiteStorage{
+		db: db,
+	}
+}
@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"home24-technical-test/config"
+	"home24-technical-test/database"
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/storage/storagetest"
+)
+
+// newTestStorage migrates a new sqlite database in the temporary directory of the test
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	connectionString := "sqlite://" + filepath.Join(t.TempDir(), "users.db")
+	if err := database.MigrateUp(&config.Config{DBConnectionString: connectionString}); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	db, err := database.Open(connectionString)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storagetest.CheckConnections(t, db)
+
+	return NewSQLiteStorage(db)
+}
+
+func TestSQLiteStorage(t *testing.T) {
+	storagetest.RunUserStorage(t, func(t *testing.T) user.Storage {
+		return newTestStorage(t)
+	})
+}
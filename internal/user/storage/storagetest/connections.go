@@ -0,0 +1,17 @@
+package storagetest
+
+import (
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// CheckConnections fails the test when connections of db are still in use once the test is done,
+// e.g. rows that were never closed. It must be called after registering the cleanup closing db
+func CheckConnections(t *testing.T, db *sqlx.DB) {
+	t.Cleanup(func() {
+		if inUse := db.Stats().InUse; inUse != 0 {
+			t.Errorf("%d database connections leaked", inUse)
+		}
+	})
+}
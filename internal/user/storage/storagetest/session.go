@@ -0,0 +1,325 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+)
+
+// RunSessionStorage runs the user.SessionStorage tests, newStorage may return the same storage every time:
+// every test works on its own tokens & user ids
+func RunSessionStorage(t *testing.T, newStorage func(t *testing.T) user.SessionStorage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s user.SessionStorage, run *sessionRun)
+	}{
+		{"NotFound", testSessionNotFound},
+		{"InsertAndFind", testSessionInsertAndFind},
+		{"TypeScoped", testSessionTypeScoped},
+		{"Expiry", testSessionExpiry},
+		{"Update", testSessionUpdate},
+		{"Delete", testSessionDelete},
+		{"UpdateByUserID", testSessionUpdateByUserID},
+		{"DeleteByUserID", testSessionDeleteByUserID},
+		{"Concurrent", testSessionConcurrent},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t), newSessionRun())
+		})
+	}
+}
+
+// sessionRun makes the tokens & the user ids of a test, unique across the runs sharing a storage
+type sessionRun struct {
+	prefix string
+	userID int
+}
+
+func newSessionRun() *sessionRun {
+	now := time.Now().UnixNano()
+	return &sessionRun{
+		prefix: fmt.Sprintf("st%d", now),
+		// far from the ids of the users of the database
+		userID: 1<<30 + int(now%(1<<20))*16,
+	}
+}
+
+// token is the token of the n-th session of the test
+func (r *sessionRun) token(n int) string {
+	return fmt.Sprintf("%s-%d", r.prefix, n)
+}
+
+// user is the n-th user of the test
+func (r *sessionRun) user(n int) int {
+	return r.userID + n
+}
+
+// newSession builds a login session of the user expiring after ttl
+func newSession(token string, userID int, ttl time.Duration) *model.Session {
+	return &model.Session{
+		ID:        token,
+		Type:      user.LoginSessionType,
+		ExpiredAt: time.Now().Add(ttl),
+		Info: map[string]interface{}{
+			"UserID":    userID,
+			"CSRFToken": "csrf-" + token,
+		},
+		User: &model.User{
+			ID:    userID,
+			Name:  fmt.Sprintf("user %d", userID),
+			Email: fmt.Sprintf("user-%d@home24.com", userID),
+		},
+	}
+}
+
+func mustInsertSession(t *testing.T, s user.SessionStorage, session *model.Session) *model.Session {
+	t.Helper()
+	if err := s.Insert(context.Background(), session); err != nil {
+		t.Fatalf("failed to insert session %s: %v", session.ID, err)
+	}
+	return session
+}
+
+func mustFindSession(t *testing.T, s user.SessionStorage, token, sessType string) *model.Session {
+	t.Helper()
+	session, err := s.FindByTokenAndType(context.Background(), token, sessType)
+	if err != nil {
+		t.Fatalf("failed to find session %s: %v", token, err)
+	}
+	return session
+}
+
+func testSessionNotFound(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	if session := mustFindSession(t, s, run.token(0), user.LoginSessionType); session != nil {
+		t.Errorf("expected no session for an unknown token, got %v", session)
+	}
+}
+
+func testSessionInsertAndFind(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	inserted := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Hour))
+
+	found := mustFindSession(t, s, inserted.ID, inserted.Type)
+	if found == nil {
+		t.Fatal("expected the inserted session to be found")
+	}
+	assertSameSession(t, inserted, found)
+
+	// the found session is a copy
+	found.Info["UserID"] = run.user(1)
+	found.User.Name = "changed"
+	again := mustFindSession(t, s, inserted.ID, inserted.Type)
+	if again == nil {
+		t.Fatal("expected the inserted session to be found")
+	}
+	assertSameSession(t, inserted, again)
+}
+
+func testSessionTypeScoped(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	inserted := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Hour))
+
+	if session := mustFindSession(t, s, inserted.ID, "password-reset"); session != nil {
+		t.Errorf("expected the session not to be found with another type, got %v", session)
+	}
+	if err := s.Delete(context.Background(), inserted.ID, "password-reset"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if session := mustFindSession(t, s, inserted.ID, inserted.Type); session == nil {
+		t.Error("expected the session to be kept when deleting another type")
+	}
+}
+
+func testSessionExpiry(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	expired := mustInsertSession(t, s, newSession(run.token(0), run.user(0), -time.Second))
+	if session := mustFindSession(t, s, expired.ID, expired.Type); session != nil {
+		t.Errorf("expected a session inserted already expired not to be found, got %v", session)
+	}
+
+	expiring := mustInsertSession(t, s, newSession(run.token(1), run.user(0), time.Second))
+	if session := mustFindSession(t, s, expiring.ID, expiring.Type); session == nil {
+		t.Fatal("expected the session to be found before it expires")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if session := mustFindSession(t, s, expiring.ID, expiring.Type); session != nil {
+		t.Errorf("expected the session to expire, got %v", session)
+	}
+	if err := s.Update(context.Background(), newSession(expiring.ID, run.user(0), time.Hour)); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Update of an expired session: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testSessionUpdate(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	ctx := context.Background()
+	inserted := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Second))
+
+	// only the expiry & the user are updated
+	update := newSession(inserted.ID, run.user(0), time.Hour)
+	update.Info = map[string]interface{}{"UserID": run.user(1)}
+	update.User.Name = "renamed"
+	if err := s.Update(ctx, update); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	found := mustFindSession(t, s, inserted.ID, inserted.Type)
+	if found == nil {
+		t.Fatal("expected the updated session to be found")
+	}
+	if found.User == nil || found.User.Name != "renamed" {
+		t.Errorf("expected the user to be updated, got %v", found.User)
+	}
+	if found.UserID() != run.user(0) || found.CSRFToken() != inserted.CSRFToken() {
+		t.Errorf("expected the info to be kept, got %v", found.Info)
+	}
+	assertExpiry(t, update.ExpiredAt, found.ExpiredAt)
+
+	// the new expiry holds
+	time.Sleep(1500 * time.Millisecond)
+	if session := mustFindSession(t, s, inserted.ID, inserted.Type); session == nil {
+		t.Error("expected the update to extend the session")
+	}
+
+	if err := s.Update(ctx, newSession(run.token(1), run.user(0), time.Hour)); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Update of an unknown session: expected ErrNotFound, got %v", err)
+	}
+	if session := mustFindSession(t, s, run.token(1), user.LoginSessionType); session != nil {
+		t.Errorf("expected Update not to insert the unknown session, got %v", session)
+	}
+}
+
+func testSessionDelete(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	ctx := context.Background()
+	deleted := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Hour))
+	kept := mustInsertSession(t, s, newSession(run.token(1), run.user(0), time.Hour))
+
+	if err := s.Delete(ctx, deleted.ID, deleted.Type); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if session := mustFindSession(t, s, deleted.ID, deleted.Type); session != nil {
+		t.Errorf("expected the session to be deleted, got %v", session)
+	}
+	if session := mustFindSession(t, s, kept.ID, kept.Type); session == nil {
+		t.Error("expected the other session to be kept")
+	}
+
+	if err := s.Delete(ctx, deleted.ID, deleted.Type); err != nil {
+		t.Errorf("expected deleting a deleted session to succeed, got %v", err)
+	}
+}
+
+func testSessionUpdateByUserID(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	first := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Hour))
+	second := mustInsertSession(t, s, newSession(run.token(1), run.user(0), time.Hour))
+	other := mustInsertSession(t, s, newSession(run.token(2), run.user(1), time.Hour))
+
+	update := newSession(run.token(3), run.user(0), time.Hour)
+	update.User.Name = "renamed"
+	if err := s.UpdateByUserID(context.Background(), update); err != nil {
+		t.Fatalf("UpdateByUserID failed: %v", err)
+	}
+
+	for _, inserted := range []*model.Session{first, second} {
+		found := mustFindSession(t, s, inserted.ID, inserted.Type)
+		if found == nil || found.User == nil || found.User.Name != "renamed" {
+			t.Errorf("expected the user of session %s to be updated, got %v", inserted.ID, found)
+			continue
+		}
+		if found.UserID() != run.user(0) {
+			t.Errorf("expected the info of session %s to be kept, got %v", inserted.ID, found.Info)
+		}
+		assertExpiry(t, inserted.ExpiredAt, found.ExpiredAt)
+	}
+
+	found := mustFindSession(t, s, other.ID, other.Type)
+	if found == nil || found.User == nil || found.User.Name != other.User.Name {
+		t.Errorf("expected the session of another user to be kept, got %v", found)
+	}
+	if session := mustFindSession(t, s, update.ID, update.Type); session != nil {
+		t.Errorf("expected UpdateByUserID not to insert a session, got %v", session)
+	}
+}
+
+func testSessionDeleteByUserID(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	first := mustInsertSession(t, s, newSession(run.token(0), run.user(0), time.Hour))
+	second := mustInsertSession(t, s, newSession(run.token(1), run.user(0), time.Hour))
+	other := mustInsertSession(t, s, newSession(run.token(2), run.user(1), time.Hour))
+
+	if err := s.DeleteByUserID(context.Background(), run.user(0)); err != nil {
+		t.Fatalf("DeleteByUserID failed: %v", err)
+	}
+
+	for _, deleted := range []*model.Session{first, second} {
+		if session := mustFindSession(t, s, deleted.ID, deleted.Type); session != nil {
+			t.Errorf("expected session %s to be deleted, got %v", deleted.ID, session)
+		}
+	}
+	if session := mustFindSession(t, s, other.ID, other.Type); session == nil {
+		t.Error("expected the session of another user to be kept")
+	}
+}
+
+func testSessionConcurrent(t *testing.T, s user.SessionStorage, run *sessionRun) {
+	const workers = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*3)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			ctx := context.Background()
+			session := newSession(run.token(i), run.user(i%2), time.Hour)
+			errs <- s.Insert(ctx, session)
+			_, err := s.FindByTokenAndType(ctx, session.ID, session.Type)
+			errs <- err
+			errs <- s.UpdateByUserID(ctx, session)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent access failed: %v", err)
+		}
+	}
+	for i := 0; i < workers; i++ {
+		if session := mustFindSession(t, s, run.token(i), user.LoginSessionType); session == nil {
+			t.Errorf("expected session %d to be found", i)
+		}
+	}
+}
+
+// assertSameSession compares the fields kept by every session storage
+func assertSameSession(t *testing.T, expected, got *model.Session) {
+	t.Helper()
+
+	if got.ID != expected.ID || got.Type != expected.Type {
+		t.Errorf("expected session %s:%s, got %s:%s", expected.Type, expected.ID, got.Type, got.ID)
+	}
+	if got.UserID() != expected.UserID() || got.CSRFToken() != expected.CSRFToken() {
+		t.Errorf("expected info %v, got %v", expected.Info, got.Info)
+	}
+	if got.User == nil || got.User.Name != expected.User.Name || got.User.Email != expected.User.Email {
+		t.Errorf("expected user %v, got %v", expected.User, got.User)
+	}
+	assertExpiry(t, expected.ExpiredAt, got.ExpiredAt)
+}
+
+// assertExpiry compares the expiry times, the stored ones may lose their monotonic clock & a bit of precision
+func assertExpiry(t *testing.T, expected, got time.Time) {
+	t.Helper()
+
+	if diff := got.Sub(expected); diff > time.Millisecond || diff < -time.Millisecond {
+		t.Errorf("expected the session to expire at %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,541 @@
+// Package storagetest holds the behavioral tests every user.Storage and user.SessionStorage must pass,
+// the storage packages run them against their own implementation
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/pagination"
+)
+
+// RunUserStorage runs the user.Storage tests, newStorage may return the same storage every time
+// as long as the users of the other tests are kept: every test works on its own users
+func RunUserStorage(t *testing.T, newStorage func(t *testing.T) user.Storage) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s user.Storage, run string)
+	}{
+		{"NotFound", testUserNotFound},
+		{"InsertAndFind", testUserInsertAndFind},
+		{"DuplicateEmail", testUserDuplicateEmail},
+		{"Update", testUserUpdate},
+		{"UpdateConflicts", testUserUpdateConflicts},
+		{"SoftDelete", testUserSoftDelete},
+		{"Restore", testUserRestore},
+		{"Purge", testUserPurge},
+		{"FindAllFilters", testUserFindAllFilters},
+		{"FindAllPagination", testUserFindAllPagination},
+		{"FindAllInvalidParams", testUserFindAllInvalidParams},
+		{"ConcurrentInserts", testUserConcurrentInserts},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// the names & emails of the run don't collide with the rows left by the previous runs
+			run := fmt.Sprintf("st%d", time.Now().UnixNano())
+			tt.test(t, newStorage(t), run)
+		})
+	}
+}
+
+// newUser builds a user with a unique email
+func newUser(run, name string) *model.User {
+	return &model.User{
+		Name:     run + "-" + name,
+		Email:    fmt.Sprintf("%s-%s@Home24.com", run, name),
+		Address:  "Berlin",
+		Password: "secret",
+
+		PhoneNumber:           "+4930123456",
+		Locale:                "de-DE",
+		Timezone:              "Europe/Berlin",
+		MarketingEmailConsent: true,
+		CustomAttributes:      model.Attributes{"loyaltyTier": "gold"},
+	}
+}
+
+func mustInsert(t *testing.T, s user.Storage, u *model.User) *model.User {
+	t.Helper()
+	if err := s.Insert(context.Background(), u); err != nil {
+		t.Fatalf("failed to insert user %s: %v", u.Email, err)
+	}
+	return u
+}
+
+func testUserNotFound(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+
+	if _, err := s.FindByID(ctx, 1<<30); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("FindByID of an unknown user: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.FindByEmail(ctx, run+"-nobody@home24.com"); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("FindByEmail of an unknown user: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, 1<<30); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Delete of an unknown user: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Restore(ctx, 1<<30); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Restore of an unknown user: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUserInsertAndFind(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	inserted := mustInsert(t, s, newUser(run, "insert"))
+
+	if inserted.ID == 0 {
+		t.Fatal("expected the id to be set on insert")
+	}
+	if inserted.Version != 1 {
+		t.Errorf("expected version 1, got %d", inserted.Version)
+	}
+	if inserted.CreatedAt.IsZero() || inserted.UpdatedAt.IsZero() {
+		t.Error("expected the timestamps to be set on insert")
+	}
+	if inserted.Email != strings.ToLower(inserted.Email) {
+		t.Errorf("expected the email to be stored lower-cased, got %s", inserted.Email)
+	}
+
+	byID, err := s.FindByID(ctx, inserted.ID)
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	assertSameUser(t, inserted, byID)
+
+	byEmail, err := s.FindByEmail(ctx, strings.ToUpper(inserted.Email))
+	if err != nil {
+		t.Fatalf("FindByEmail is expected to be case-insensitive: %v", err)
+	}
+	assertSameUser(t, inserted, byEmail)
+
+	// the found user is a copy
+	byID.Name = "changed"
+	byID.CustomAttributes["loyaltyTier"] = "silver"
+	again, err := s.FindByID(ctx, inserted.ID)
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	assertSameUser(t, inserted, again)
+}
+
+func testUserDuplicateEmail(t *testing.T, s user.Storage, run string) {
+	mustInsert(t, s, newUser(run, "duplicate"))
+
+	duplicate := newUser(run, "duplicate")
+	duplicate.Email = strings.ToUpper(duplicate.Email)
+	if err := s.Insert(context.Background(), duplicate); !errors.Is(err, user.ErrEmailAlreadyExists) {
+		t.Errorf("expected ErrEmailAlreadyExists, got %v", err)
+	}
+}
+
+func testUserUpdate(t *testing.T, s user.Storage, run string) {
+	ctx := context.WithValue(context.Background(), appcontext.KeyUserID, 42)
+	inserted := mustInsert(t, s, newUser(run, "update"))
+
+	updated := *inserted
+	updated.Address = "Hamburg"
+	updated.Email = strings.ToUpper(run) + "-updated@home24.com"
+	updated.CustomAttributes = model.Attributes{"loyaltyTier": "silver"}
+	if err := s.Update(ctx, &updated); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Version != inserted.Version+1 {
+		t.Errorf("expected version %d, got %d", inserted.Version+1, updated.Version)
+	}
+	if updated.UpdatedBy != 42 {
+		t.Errorf("expected the user of the context as updater, got %d", updated.UpdatedBy)
+	}
+	if updated.Email != strings.ToLower(updated.Email) {
+		t.Errorf("expected the email to be stored lower-cased, got %s", updated.Email)
+	}
+
+	found, err := s.FindByID(ctx, inserted.ID)
+	if err != nil {
+		t.Fatalf("FindByID failed: %v", err)
+	}
+	assertSameUser(t, &updated, found)
+	if !found.CreatedAt.Equal(inserted.CreatedAt) {
+		t.Errorf("expected the creation time to be kept, got %s instead of %s", found.CreatedAt, inserted.CreatedAt)
+	}
+}
+
+func testUserUpdateConflicts(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	inserted := mustInsert(t, s, newUser(run, "conflict"))
+	other := mustInsert(t, s, newUser(run, "other"))
+
+	first := *inserted
+	first.Address = "Hamburg"
+	if err := s.Update(ctx, &first); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	stale := *inserted
+	stale.Address = "Munich"
+	if err := s.Update(ctx, &stale); !errors.Is(err, user.ErrVersionConflict) {
+		t.Errorf("update of a stale version: expected ErrVersionConflict, got %v", err)
+	}
+
+	taken := first
+	taken.Email = other.Email
+	if err := s.Update(ctx, &taken); !errors.Is(err, user.ErrEmailAlreadyExists) {
+		t.Errorf("update to the email of another user: expected ErrEmailAlreadyExists, got %v", err)
+	}
+
+	unknown := first
+	unknown.ID = 1 << 30
+	if err := s.Update(ctx, &unknown); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("update of an unknown user: expected ErrNotFound, got %v", err)
+	}
+}
+
+func testUserSoftDelete(t *testing.T, s user.Storage, run string) {
+	ctx := context.WithValue(context.Background(), appcontext.KeyUserID, 7)
+	deleted := mustInsert(t, s, newUser(run, "deleted"))
+
+	if err := s.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	if _, err := s.FindByID(ctx, deleted.ID); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("FindByID of a deleted user: expected ErrNotFound, got %v", err)
+	}
+	if _, err := s.FindByEmail(ctx, deleted.Email); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("FindByEmail of a deleted user: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Delete(ctx, deleted.ID); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Delete of a deleted user: expected ErrNotFound, got %v", err)
+	}
+	if err := s.Update(ctx, deleted); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Update of a deleted user: expected ErrNotFound, got %v", err)
+	}
+
+	page, err := s.FindAllDeleted(ctx, &public.FindAllUsersParams{Search: run})
+	if err != nil {
+		t.Fatalf("FindAllDeleted failed: %v", err)
+	}
+	if len(page.Users) != 1 || page.Users[0].ID != deleted.ID {
+		t.Fatalf("expected the deleted user to be listed, got %v", userIDs(page.Users))
+	}
+	if page.Users[0].DeletedAt == nil || page.Users[0].DeletedBy == nil || *page.Users[0].DeletedBy != 7 {
+		t.Errorf("expected the deletion time & the user of the context as deleter, got %v and %v", page.Users[0].DeletedAt, page.Users[0].DeletedBy)
+	}
+
+	active, err := s.FindAll(ctx, &public.FindAllUsersParams{Search: run})
+	if err != nil {
+		t.Fatalf("FindAll failed: %v", err)
+	}
+	if len(active.Users) != 0 {
+		t.Errorf("expected the deleted user not to be listed, got %v", userIDs(active.Users))
+	}
+
+	// the email of a deleted user can be used again
+	mustInsert(t, s, newUser(run, "deleted"))
+}
+
+func testUserRestore(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	restored := mustInsert(t, s, newUser(run, "restored"))
+
+	if err := s.Restore(ctx, restored.ID); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Restore of an active user: expected ErrNotFound, got %v", err)
+	}
+
+	if err := s.Delete(ctx, restored.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Restore(ctx, restored.ID); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if _, err := s.FindByID(ctx, restored.ID); err != nil {
+		t.Errorf("FindByID of a restored user failed: %v", err)
+	}
+
+	// the email was taken in the meantime
+	if err := s.Delete(ctx, restored.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	mustInsert(t, s, newUser(run, "restored"))
+	if err := s.Restore(ctx, restored.ID); !errors.Is(err, user.ErrEmailAlreadyExists) {
+		t.Errorf("Restore of a user whose email was taken: expected ErrEmailAlreadyExists, got %v", err)
+	}
+}
+
+func testUserPurge(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	purgedUser := newUser(run, "purged")
+	purgedUser.Avatar = "avatars/" + run + ".png"
+	purged := mustInsert(t, s, purgedUser)
+	kept := mustInsert(t, s, newUser(run, "kept"))
+
+	if err := s.Delete(ctx, purged.ID); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	found, err := s.FindDeletedBefore(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("FindDeletedBefore failed: %v", err)
+	}
+	if containsID(userIDs(found), purged.ID) {
+		t.Errorf("expected the user deleted after the purge time not to be found")
+	}
+	found, err = s.FindDeletedBefore(ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("FindDeletedBefore failed: %v", err)
+	}
+	if !containsID(userIDs(found), purged.ID) || containsID(userIDs(found), kept.ID) {
+		t.Errorf("expected only the deleted user %d to be found, got %v", purged.ID, userIDs(found))
+	}
+	for _, u := range found {
+		if u.ID == purged.ID && u.Avatar != purgedUser.Avatar {
+			t.Errorf("expected the avatar %s of the deleted user, got %q", purgedUser.Avatar, u.Avatar)
+		}
+	}
+
+	ids, err := s.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if containsID(ids, purged.ID) {
+		t.Errorf("expected the user deleted after the purge time to be kept")
+	}
+
+	ids, err = s.Purge(ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if !containsID(ids, purged.ID) || containsID(ids, kept.ID) {
+		t.Errorf("expected only the deleted user %d to be purged, got %v", purged.ID, ids)
+	}
+
+	page, err := s.FindAllDeleted(ctx, &public.FindAllUsersParams{Search: run})
+	if err != nil {
+		t.Fatalf("FindAllDeleted failed: %v", err)
+	}
+	if len(page.Users) != 0 {
+		t.Errorf("expected the purged user to be gone, got %v", userIDs(page.Users))
+	}
+	if err := s.Restore(ctx, purged.ID); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("Restore of a purged user: expected ErrNotFound, got %v", err)
+	}
+	if found, err := s.FindDeletedBefore(ctx, time.Now().Add(time.Second)); err != nil || containsID(userIDs(found), purged.ID) {
+		t.Errorf("expected the purged user not to be found, got %v, %v", userIDs(found), err)
+	}
+}
+
+func testUserFindAllFilters(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	alice := newUser(run, "alice")
+	alice.Address = "Hamburg"
+	mustInsert(t, s, alice)
+	// the creation times differ even with the microsecond precision of the database
+	time.Sleep(time.Millisecond)
+	bob := mustInsert(t, s, newUser(run, "bob"))
+
+	tests := []struct {
+		name     string
+		params   public.FindAllUsersParams
+		expected []int
+	}{
+		{"search by name", public.FindAllUsersParams{Search: run + "-ALICE"}, []int{alice.ID}},
+		{"search by address", public.FindAllUsersParams{Search: "HAMBURG", Name: run}, []int{alice.ID}},
+		{"search by email", public.FindAllUsersParams{Search: run + "-bob@"}, []int{bob.ID}},
+		{"name", public.FindAllUsersParams{Name: run + "-bob"}, []int{bob.ID}},
+		{"email", public.FindAllUsersParams{Email: run + "-alice@"}, []int{alice.ID}},
+		{"like wildcards are literal", public.FindAllUsersParams{Search: run + "%"}, nil},
+		{"created from", public.FindAllUsersParams{Search: run, CreatedFrom: &bob.CreatedAt}, []int{bob.ID}},
+		{"created to", public.FindAllUsersParams{Search: run, CreatedTo: &alice.CreatedAt}, []int{alice.ID}},
+	}
+
+	for _, tt := range tests {
+		params := tt.params
+		params.SortOrder = public.SortAsc
+		page, err := s.FindAll(ctx, &params)
+		if err != nil {
+			t.Fatalf("%s: FindAll failed: %v", tt.name, err)
+		}
+		if got := userIDs(page.Users); !equalIDs(got, tt.expected) || page.Total != len(tt.expected) {
+			t.Errorf("%s: expected users %v, got %v (total %d)", tt.name, tt.expected, got, page.Total)
+		}
+	}
+}
+
+func testUserFindAllPagination(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+	var inserted []*model.User
+	for _, name := range []string{"d", "a", "e", "c", "b"} {
+		inserted = append(inserted, mustInsert(t, s, newUser(run, name)))
+	}
+	a, b, c, d, e := inserted[1].ID, inserted[4].ID, inserted[3].ID, inserted[0].ID, inserted[2].ID
+
+	tests := []struct {
+		sortBy    string
+		sortOrder string
+		expected  []int
+	}{
+		{public.SortByName, public.SortAsc, []int{a, b, c, d, e}},
+		{public.SortByName, public.SortDesc, []int{e, d, c, b, a}},
+		{public.SortByEmail, public.SortAsc, []int{a, b, c, d, e}},
+		{public.SortByID, public.SortAsc, []int{d, a, e, c, b}},
+		{public.SortByID, "", []int{b, c, e, a, d}},
+		{public.SortByCreatedAt, public.SortAsc, []int{d, a, e, c, b}},
+	}
+
+	for _, tt := range tests {
+		name := tt.sortBy + " " + tt.sortOrder
+		params := public.FindAllUsersParams{Search: run, SortBy: tt.sortBy, SortOrder: tt.sortOrder, Limit: 2}
+
+		// forward through all the pages
+		var got []int
+		var cursors []string
+		for page := 0; ; page++ {
+			if page > 3 {
+				t.Fatalf("%s: too many pages", name)
+			}
+			result, err := s.FindAll(ctx, &params)
+			if err != nil {
+				t.Fatalf("%s: FindAll failed: %v", name, err)
+			}
+			if result.Total != 5 {
+				t.Errorf("%s: expected total 5, got %d", name, result.Total)
+			}
+			if (page == 0) != (result.PrevCursor == "") {
+				t.Errorf("%s: page %d has prev cursor %q", name, page, result.PrevCursor)
+			}
+			got = append(got, userIDs(result.Users)...)
+			cursors = append(cursors, result.PrevCursor)
+			if result.NextCursor == "" {
+				break
+			}
+			params.Cursor = result.NextCursor
+		}
+		if !equalIDs(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", name, tt.expected, got)
+		}
+
+		// back from the last page
+		params.Cursor = cursors[len(cursors)-1]
+		previous, err := s.FindAll(ctx, &params)
+		if err != nil {
+			t.Fatalf("%s: FindAll backward failed: %v", name, err)
+		}
+		if !equalIDs(userIDs(previous.Users), tt.expected[2:4]) {
+			t.Errorf("%s: expected the previous page %v, got %v", name, tt.expected[2:4], userIDs(previous.Users))
+		}
+		if previous.NextCursor == "" || previous.PrevCursor == "" {
+			t.Errorf("%s: expected the middle page to have both cursors", name)
+		}
+	}
+}
+
+func testUserFindAllInvalidParams(t *testing.T, s user.Storage, run string) {
+	ctx := context.Background()
+
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{SortBy: "password"}); !errors.Is(err, user.ErrInvalidSort) {
+		t.Errorf("unknown sort field: expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{SortOrder: "up"}); !errors.Is(err, user.ErrInvalidSort) {
+		t.Errorf("unknown sort order: expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{Cursor: "not a cursor"}); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Errorf("malformed cursor: expected ErrInvalidCursor, got %v", err)
+	}
+
+	byName := (&pagination.Cursor{SortBy: public.SortByName, SortOrder: public.SortDesc, Value: "a", ID: 1}).Encode()
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{SortBy: public.SortByEmail, Cursor: byName}); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Errorf("cursor of another sort: expected ErrInvalidCursor, got %v", err)
+	}
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{SortBy: public.SortByName, Cursor: byName}); err != nil {
+		t.Errorf("cursor of the sort: expected no error, got %v", err)
+	}
+	if _, err := s.FindAll(ctx, &public.FindAllUsersParams{SortBy: public.SortByName, SortOrder: public.SortAsc, Cursor: byName}); !errors.Is(err, pagination.ErrInvalidCursor) {
+		t.Errorf("cursor of another sort order: expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func testUserConcurrentInserts(t *testing.T, s user.Storage, run string) {
+	const writers = 10
+
+	var wg sync.WaitGroup
+	users := make([]*model.User, writers)
+	errs := make([]error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			users[i] = newUser(run, fmt.Sprintf("concurrent-%d", i))
+			errs[i] = s.Insert(context.Background(), users[i])
+		}(i)
+	}
+	wg.Wait()
+
+	ids := map[int]bool{}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent insert failed: %v", err)
+		}
+		ids[users[i].ID] = true
+	}
+	if len(ids) != writers {
+		t.Errorf("expected %d distinct ids, got %d", writers, len(ids))
+	}
+}
+
+// assertSameUser compares the stored fields of the users
+func assertSameUser(t *testing.T, expected, got *model.User) {
+	t.Helper()
+
+	if got.ID != expected.ID || got.Name != expected.Name || got.Email != expected.Email || got.Address != expected.Address ||
+		got.Password != expected.Password || got.Version != expected.Version {
+		t.Errorf("expected user %d %s <%s> %s v%d, got %d %s <%s> %s v%d",
+			expected.ID, expected.Name, expected.Email, expected.Address, expected.Version,
+			got.ID, got.Name, got.Email, got.Address, got.Version)
+	}
+	if got.PhoneNumber != expected.PhoneNumber || got.Locale != expected.Locale || got.Timezone != expected.Timezone ||
+		got.MarketingEmailConsent != expected.MarketingEmailConsent || got.MarketingSMSConsent != expected.MarketingSMSConsent {
+		t.Errorf("expected the profile of user %d to be kept", expected.ID)
+	}
+	if fmt.Sprint(got.CustomAttributes) != fmt.Sprint(expected.CustomAttributes) {
+		t.Errorf("expected custom attributes %v, got %v", expected.CustomAttributes, got.CustomAttributes)
+	}
+}
+
+func userIDs(users []*model.User) []int {
+	ids := []int{}
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	return ids
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func equalIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
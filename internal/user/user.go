@@ -3,58 +3,85 @@ package user
 import (
 	"context"
 	"errors"
+	"io"
+	"log"
+	"strings"
 	"time"
 
 	"home24-technical-test/internal/user/model"
 	"home24-technical-test/internal/user/public"
 	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/blobstore"
+	"home24-technical-test/pkg/data"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Storage represents the user storage interface
 type Storage interface {
-	FindAll(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error)
+	FindAll(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
 	FindByID(ctx context.Context, userID int) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
 	Insert(ctx context.Context, user *model.User) error
 	Update(ctx context.Context, updatedUser *model.User) error
 	Delete(ctx context.Context, userID int) error
+	FindAllDeleted(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
+	Restore(ctx context.Context, userID int) error
+	FindDeletedBefore(ctx context.Context, deletedBefore time.Time) ([]*model.User, error)
+	Purge(ctx context.Context, deletedBefore time.Time) ([]int, error)
 }
 
 // ServiceInterface represents the user service interface
 type ServiceInterface interface {
-	ListUsers(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error)
+	ListUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
 	GetUser(ctx context.Context, userID int) (*model.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 	CreateUser(ctx context.Context, params *public.CreateUserParams) error
 	UpdateUser(ctx context.Context, params *public.UpdateUserParams) (*model.User, error)
 	DeleteUser(ctx context.Context, userID int) error
 	ChangePassword(ctx context.Context, userID int, oldPassword, newPassword string) error
+	ListDeletedUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error)
+	RestoreUser(ctx context.Context, userID int) error
+	ListPurgeableUsers(ctx context.Context, deletedBefore time.Time) ([]int, error)
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]int, error)
+	UploadAvatar(ctx context.Context, userID int, avatar io.Reader) (*model.User, error)
+	GetAvatar(ctx context.Context, userID int) (io.ReadCloser, error)
 }
 
 // Errors
 var (
-	ErrWrongPassword      = errors.New("wrong password")
-	ErrWrongEmail         = errors.New("wrong email")
-	ErrEmailAlreadyExists = errors.New("Email Already Exists")
-	ErrNotFound           = errors.New("not found")
-	ErrNoInput            = errors.New("no input")
+	ErrWrongPassword      = apperror.New(apperror.CodeInvalidArgument, "wrong password")
+	ErrWrongEmail         = apperror.New(apperror.CodeInvalidArgument, "wrong email")
+	ErrInvalidCredentials = apperror.New(apperror.CodeInvalidArgument, "email or password is wrong")
+	ErrEmailAlreadyExists = apperror.New(apperror.CodeConflict, "email already exists")
+	ErrNotFound           = data.ErrNotFound
+	ErrNoInput            = apperror.New(apperror.CodeInvalidArgument, "no input")
+	ErrInvalidSort        = apperror.New(apperror.CodeInvalidArgument, "invalid sort")
+	ErrVersionConflict    = apperror.New(apperror.CodePreconditionFailed, "user was modified in the meantime")
 )
 
+// NormalizeEmail normalizes the email so it can be compared case-insensitively
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // Service is the domain logic implementation of user Service interface
 type Service struct {
-	repository Storage
+	repository      Storage
+	avatarStore     blobstore.Store
+	attributeSchema AttributeSchema
+	avatarMaxSize   int64
 }
 
 // ListUsers is listing all Users
-func (s *Service) ListUsers(ctx context.Context, params *public.FindAllUsersParams) ([]*model.User, error) {
-	users, err := s.repository.FindAll(ctx, params)
+func (s *Service) ListUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	page, err := s.repository.FindAll(ctx, params)
 	if err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	return page, nil
 }
 
 // GetUser get user by its id
@@ -69,7 +96,7 @@ func (s *Service) GetUser(ctx context.Context, userID int) (*model.User, error)
 
 // GetUserByEmail get user by its email
 func (s *Service) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
-	user, err := s.repository.FindByEmail(ctx, email)
+	user, err := s.repository.FindByEmail(ctx, NormalizeEmail(email))
 	if err != nil {
 		return nil, err
 	}
@@ -79,8 +106,10 @@ func (s *Service) GetUserByEmail(ctx context.Context, email string) (*model.User
 
 // UpdateUser updates users data
 func (s *Service) UpdateUser(ctx context.Context, params *public.UpdateUserParams) (*model.User, error) {
-	user, err := s.repository.FindByEmail(ctx, params.Email)
-	if err != nil {
+	email := NormalizeEmail(params.Email)
+
+	user, err := s.repository.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return nil, err
 	}
 
@@ -93,20 +122,24 @@ func (s *Service) UpdateUser(ctx context.Context, params *public.UpdateUserParam
 		return nil, err
 	}
 
+	if params.Version != 0 && params.Version != updatedUser.Version {
+		return nil, ErrVersionConflict
+	}
+
 	if params.Name != "" {
 		updatedUser.Name = params.Name
 	}
-	if params.Email != "" {
-		updatedUser.Email = params.Email
+	if email != "" {
+		updatedUser.Email = email
 	}
 	if params.Address != "" {
 		updatedUser.Address = params.Address
 	}
 
-	currentUserID := appcontext.UserID(ctx)
-
-	updatedUser.UpdatedBy = currentUserID
-	updatedUser.UpdatedAt = time.Now()
+	err = s.applyProfile(updatedUser, params)
+	if err != nil {
+		return nil, err
+	}
 
 	err = s.repository.Update(ctx, updatedUser)
 	if err != nil {
@@ -142,7 +175,7 @@ func (s *Service) ChangePassword(ctx context.Context, userID int, oldPassword, n
 	return nil
 }
 
-//DeleteUser deleting user and its session
+// DeleteUser deleting user and its session
 func (s *Service) DeleteUser(ctx context.Context, userID int) error {
 	singleUser, err := s.repository.FindByID(ctx, userID)
 	if err != nil {
@@ -157,6 +190,63 @@ func (s *Service) DeleteUser(ctx context.Context, userID int) error {
 	return nil
 }
 
+// ListDeletedUsers is listing all soft deleted users
+func (s *Service) ListDeletedUsers(ctx context.Context, params *public.FindAllUsersParams) (*public.UsersPage, error) {
+	page, err := s.repository.FindAllDeleted(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// RestoreUser restores a soft deleted user
+func (s *Service) RestoreUser(ctx context.Context, userID int) error {
+	return s.repository.Restore(ctx, userID)
+}
+
+// ListPurgeableUsers lists the ids of the users soft deleted before the given time
+func (s *Service) ListPurgeableUsers(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	users, err := s.repository.FindDeletedBefore(ctx, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, u.ID)
+	}
+	return userIDs, nil
+}
+
+// PurgeDeletedUsers permanently removes users soft deleted before the given time & their avatars.
+// The avatars go once the users are gone, a failed avatar deletion is logged & leaves the file behind
+func (s *Service) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) ([]int, error) {
+	users, err := s.repository.FindDeletedBefore(ctx, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := s.repository.Purge(ctx, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	avatars := make(map[int]string, len(users))
+	for _, u := range users {
+		avatars[u.ID] = u.Avatar
+	}
+	for _, userID := range userIDs {
+		if avatar := avatars[userID]; avatar != "" {
+			if err := s.avatarStore.Delete(ctx, avatar); err != nil {
+				log.Printf("failed to delete the avatar %s of the purged user %d: %v", avatar, userID, err)
+			}
+		}
+	}
+
+	return userIDs, nil
+}
+
 // CreateUser creates a new user
 func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParams) error {
 	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
@@ -164,8 +254,11 @@ func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParam
 		return err
 	}
 
-	existingUser, err := s.repository.FindByEmail(ctx, params.Email)
-	if err != nil {
+	email := NormalizeEmail(params.Email)
+
+	// checked upfront for a friendly error, the unique index guards concurrent signups
+	existingUser, err := s.repository.FindByEmail(ctx, email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return err
 	}
 
@@ -177,7 +270,7 @@ func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParam
 
 	user := &model.User{
 		Name:      params.Name,
-		Email:     params.Email,
+		Email:     email,
 		Address:   params.Address,
 		Password:  string(bcryptHash),
 		CreatedBy: currentUserID,
@@ -197,8 +290,14 @@ func (s *Service) CreateUser(ctx context.Context, params *public.CreateUserParam
 // NewService creates a new user AppService
 func NewService(
 	userRepository Storage,
+	avatarStore blobstore.Store,
+	attributeSchema AttributeSchema,
+	avatarMaxSize int64,
 ) *Service {
 	return &Service{
-		repository: userRepository,
+		repository:      userRepository,
+		avatarStore:     avatarStore,
+		attributeSchema: attributeSchema,
+		avatarMaxSize:   avatarMaxSize,
 	}
 }
@@ -12,6 +12,12 @@ const (
 
 	// KeySessionID represents the current logged-in SessionID
 	KeySessionID contextKey = "SessionID"
+
+	// KeyServiceIdentity represents the internal service calling with a client certificate
+	KeyServiceIdentity contextKey = "ServiceIdentity"
+
+	// KeyRoles represents the roles granted to the current session
+	KeyRoles contextKey = "Roles"
 )
 
 // UserID gets current userId logged in from the context
@@ -33,3 +39,33 @@ func SessionID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// ServiceIdentity gets the identity of the internal service calling from the context
+func ServiceIdentity(ctx context.Context) string {
+	identity := (ctx).Value(KeyServiceIdentity)
+	if identity != nil {
+		v := identity.(string)
+		return v
+	}
+	return ""
+}
+
+// Roles gets the roles granted to the current session from the context
+func Roles(ctx context.Context) []string {
+	roles := (ctx).Value(KeyRoles)
+	if roles != nil {
+		v := roles.([]string)
+		return v
+	}
+	return nil
+}
+
+// HasRole tells whether the current session was granted the role
+func HasRole(ctx context.Context, role string) bool {
+	for _, r := range Roles(ctx) {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
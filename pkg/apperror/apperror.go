@@ -0,0 +1,122 @@
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code classifies an error, every code maps to an http status
+type Code string
+
+// Error codes
+const (
+	CodeInvalidArgument      Code = "invalid_argument"
+	CodeUnauthenticated      Code = "unauthenticated"
+	CodePermissionDenied     Code = "permission_denied"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodePreconditionFailed   Code = "precondition_failed"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodePreconditionRequired Code = "precondition_required"
+	CodeTooManyRequests      Code = "too_many_requests"
+	CodeInternal             Code = "internal"
+	CodeUnavailable          Code = "unavailable"
+)
+
+var httpStatuses = map[Code]int{
+	CodeInvalidArgument:      http.StatusBadRequest,
+	CodeUnauthenticated:      http.StatusUnauthorized,
+	CodePermissionDenied:     http.StatusForbidden,
+	CodeNotFound:             http.StatusNotFound,
+	CodeConflict:             http.StatusConflict,
+	CodePreconditionFailed:   http.StatusPreconditionFailed,
+	CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeValidationFailed:     http.StatusUnprocessableEntity,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodePreconditionRequired: http.StatusPreconditionRequired,
+	CodeTooManyRequests:      http.StatusTooManyRequests,
+	CodeInternal:             http.StatusInternalServerError,
+	CodeUnavailable:          http.StatusServiceUnavailable,
+}
+
+// FieldError describes why a single field of the request is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error represents an application error with a code & a message safe to show to the clients
+type Error struct {
+	Code    Code
+	Message string
+	// Fields lists the invalid fields of a validation error
+	Fields []FieldError
+	// Err is the underlying cause, if any
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New creates a new application error
+func New(code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// Wrap creates a new application error caused by err
+func Wrap(err error, code Code, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// NewValidation creates a validation error of the given invalid fields
+func NewValidation(fields []FieldError) *Error {
+	return &Error{
+		Code:    CodeValidationFailed,
+		Message: "validation failed",
+		Fields:  fields,
+	}
+}
+
+// FieldsOf returns the invalid fields of the first application error in the chain
+func FieldsOf(err error) []FieldError {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Fields
+	}
+	return nil
+}
+
+// CodeOf returns the code of the first application error in the chain, CodeInternal when there is none
+func CodeOf(err error) Code {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Code
+	}
+	return CodeInternal
+}
+
+// HTTPStatus maps the error to its http status
+func HTTPStatus(err error) int {
+	if status, ok := httpStatuses[CodeOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,22 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+
+	"home24-technical-test/pkg/apperror"
+)
+
+var (
+	// ErrNotFound is returned when the blob does not exist
+	ErrNotFound = apperror.New(apperror.CodeNotFound, "blob is not found")
+	// ErrInvalidKey is returned when the blob key can not be stored
+	ErrInvalidKey = apperror.New(apperror.CodeInvalidArgument, "invalid blob key")
+)
+
+// Store represents the blob storage interface
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,238 @@
+// Package client is the typed Go client of the user service API.
+//
+//	c, err := client.NewClient("http://127.0.0.1:8089", client.Options{})
+//	login, err := c.Login(ctx, email, password)
+//	u, err := c.WithSession(login.SessionID).GetUser(ctx, id)
+//
+// Error responses are returned as *Error, use apperror.CodeOf to check their code.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTimeout      = 30 * time.Second
+	defaultMaxRetries   = 2
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+// Options configures the client, the zero value uses the defaults
+type Options struct {
+	// HTTPClient sends the requests, defaults to a client with a 30s timeout
+	HTTPClient *http.Client
+	// MaxRetries is the number of retries of idempotent requests, defaults to 2, negative disables the retries
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry, doubled on every retry. Defaults to 100ms
+	RetryBackoff time.Duration
+	// ClientID & ClientSecret authenticate Introspect, APIKey is used instead when they are empty
+	ClientID     string
+	ClientSecret string
+	APIKey       string
+}
+
+// Client is the typed client of the user service API
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+	clientID     string
+	clientSecret string
+	apiKey       string
+	sessionID    string
+}
+
+// NewClient creates a new client of the user service listening on baseURL, e.g. http://127.0.0.1:8089
+func NewClient(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %v", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base url: %s", baseURL)
+	}
+
+	c := &Client{
+		baseURL:      u,
+		httpClient:   opts.HTTPClient,
+		maxRetries:   opts.MaxRetries,
+		retryBackoff: opts.RetryBackoff,
+		clientID:     opts.ClientID,
+		clientSecret: opts.ClientSecret,
+		apiKey:       opts.APIKey,
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: defaultTimeout}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.retryBackoff <= 0 {
+		c.retryBackoff = defaultRetryBackoff
+	}
+
+	return c, nil
+}
+
+// WithSession returns a copy of the client authorized with the given session id
+func (c *Client) WithSession(sessionID string) *Client {
+	clone := *c
+	clone.sessionID = sessionID
+	return &clone
+}
+
+// SessionID returns the session id the client is authorized with
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// request describes a single API call
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+	// clientAuth authenticates the request with the client credentials instead of the session
+	clientAuth bool
+}
+
+// newJSONRequest builds a request with v encoded as the json body
+func newJSONRequest(method, path string, v interface{}) (*request, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request body: %v", err)
+	}
+
+	return &request{
+		method:      method,
+		path:        path,
+		body:        body,
+		contentType: "application/json",
+	}, nil
+}
+
+// do sends the request, retrying idempotent requests on network errors & temporary server errors.
+// The caller must close the body of the returned response, error responses are returned as *Error
+func (c *Client) do(ctx context.Context, req *request) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(req.method) {
+		retries = c.maxRetries
+	}
+
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req)
+		if attempt >= retries || !shouldRetry(ctx, resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= http.StatusBadRequest {
+				defer resp.Body.Close()
+				return nil, decodeError(resp)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, req *request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequest(req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	for key, values := range req.header {
+		httpReq.Header[key] = values
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	switch {
+	case req.clientAuth && c.clientID != "":
+		httpReq.SetBasicAuth(c.clientID, c.clientSecret)
+	case req.clientAuth:
+		httpReq.Header.Set("X-API-Key", c.apiKey)
+	case c.sessionID != "":
+		httpReq.Header.Set("Authorization", "session "+c.sessionID)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// isIdempotent tells whether the request can be sent again without changing its effect
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// shouldRetry tells whether the failed attempt is worth retrying
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	// transport errors, the request may not have reached the server
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodeJSON decodes the response body into v and closes it
+func decodeJSON(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response body: %v", err)
+	}
+	return nil
+}
+
+// discard drains & closes the response body so the connection can be reused
+func discard(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
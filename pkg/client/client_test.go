@@ -0,0 +1,366 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	internalhttp "home24-technical-test/internal/http"
+	"home24-technical-test/internal/http/controller"
+	"home24-technical-test/internal/user"
+	"home24-technical-test/internal/user/adapter"
+	"home24-technical-test/internal/user/model"
+	"home24-technical-test/internal/user/public"
+	"home24-technical-test/internal/user/service/mocks"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/data"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/mock"
+)
+
+const testSessionID = "test-token"
+
+// nopDriver is a database driver whose transactions do nothing, the service behind the router is mocked
+type nopDriver struct{}
+
+func (nopDriver) Open(name string) (driver.Conn, error) { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (nopConn) Close() error                              { return nil }
+func (nopConn) Begin() (driver.Tx, error)                 { return nopConn{}, nil }
+func (nopConn) Commit() error                             { return nil }
+func (nopConn) Rollback() error                           { return nil }
+
+func init() {
+	sql.Register("clienttest-nop", nopDriver{})
+}
+
+// newTestServer runs the real router over a mocked user service, the mock already authorizes testSessionID as user 1,
+// an admin so every route is allowed
+func newTestServer(t *testing.T, wrap func(http.Handler) http.Handler) (*mocks.ServiceInterface, *httptest.Server) {
+	svc := &mocks.ServiceInterface{}
+	svc.On("GetLoginSession", mock.Anything, testSessionID).Return(&model.Session{
+		ID:   testSessionID,
+		Type: "login",
+		Info: map[string]interface{}{"UserID": float64(1), "Roles": []interface{}{"user", user.AdminRole}},
+		User: &model.User{Name: "user", Email: "user@mail.com"},
+	}, nil)
+	svc.On("GetLoginSession", mock.Anything, mock.Anything).Return(nil, nil)
+	svc.On("GetUser", mock.Anything, 1).Return(&model.User{ID: 1, Name: "user", Email: "user@mail.com", Version: 3}, nil).Maybe()
+
+	db, err := sqlx.Open("clienttest-nop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := internalhttp.NewServer(adapter.NewAdapters(svc), data.NewManager(db), internalhttp.Settings{
+		ClientCredentials: internalhttp.ClientCredentials{
+			Clients: map[string]string{"test-client": "test-secret"},
+			APIKeys: []string{"test-api-key"},
+		},
+		SessionCookie: controller.SessionCookie{Name: "sessionId", Secure: true, SameSite: http.SameSiteLaxMode},
+		CORSPolicy:    internalhttp.CORSPolicy{AllowedMethods: []string{http.MethodGet}},
+		SecurityHeaders: internalhttp.SecurityHeaders{
+			ContentSecurityPolicy:     "default-src 'none'",
+			HTMLContentSecurityPolicy: "default-src 'none'",
+			ReferrerPolicy:            "no-referrer",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var handler http.Handler = s.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+
+	return svc, ts
+}
+
+func newTestClient(t *testing.T, ts *httptest.Server) *Client {
+	c, err := NewClient(ts.URL, Options{HTTPClient: ts.Client(), RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient_LoginSessionAndUser(t *testing.T) {
+	svc, ts := newTestServer(t, nil)
+	svc.On("Login", mock.Anything, &public.LoginParams{Email: "user@mail.com", Password: "user"}).
+		Return(&public.LoginResponse{SessionID: testSessionID, User: &model.User{Name: "user", Email: "user@mail.com"}}, nil)
+	svc.On("UpdateUser", mock.Anything, mock.MatchedBy(func(p *public.UpdateUserParams) bool {
+		return p.ID == 1 && p.Version == 3 && p.Name == "new name"
+	})).Return(&model.User{ID: 1, Name: "new name", Email: "user@mail.com", Version: 4}, nil)
+	svc.On("ListUsers", mock.Anything, mock.MatchedBy(func(p *public.FindAllUsersParams) bool {
+		return p.Search == "user" && p.SortBy == SortByName && p.Limit == 5
+	})).Return(&public.UsersPage{Users: []*model.User{{ID: 1, Name: "user"}}, Total: 1, NextCursor: "next"}, nil)
+	svc.On("Logout", mock.Anything, testSessionID).Return(nil)
+
+	ctx := context.Background()
+	c := newTestClient(t, ts)
+
+	login, err := c.Login(ctx, "user@mail.com", "user")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if login.SessionID != testSessionID || login.User.Email != "user@mail.com" {
+		t.Fatalf("unexpected login response: %+v", login)
+	}
+
+	c = c.WithSession(login.SessionID)
+
+	session, err := c.GetSession(ctx)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.UserID() != 1 {
+		t.Errorf("expected session of user 1, got %d", session.UserID())
+	}
+
+	u, err := c.GetUser(ctx, 1)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if u.ID != 1 || u.ETag != `"3"` {
+		t.Errorf("unexpected user: %+v", u)
+	}
+
+	u, err = c.UpdateUser(ctx, 1, u.ETag, &UpdateUserParams{Name: "new name"})
+	if err != nil {
+		t.Fatalf("update user: %v", err)
+	}
+	if u.Name != "new name" || u.ETag != `"4"` {
+		t.Errorf("unexpected updated user: %+v", u)
+	}
+
+	page, err := c.ListUsers(ctx, &ListUsersParams{Search: "user", SortBy: SortByName, Limit: 5})
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if page.Total != 1 || len(page.Users) != 1 || page.NextCursor != "next" {
+		t.Errorf("unexpected users page: %+v", page)
+	}
+
+	if err := c.Logout(ctx); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	svc.AssertExpectations(t)
+}
+
+func TestClient_Errors(t *testing.T) {
+	svc, ts := newTestServer(t, nil)
+	svc.On("GetUser", mock.Anything, 2).Return(nil, user.ErrNotFound)
+
+	ctx := context.Background()
+	c := newTestClient(t, ts)
+
+	tests := []struct {
+		name   string
+		call   func() error
+		status int
+		code   apperror.Code
+		fields int
+	}{
+		{
+			name:   "missing session",
+			call:   func() error { _, err := c.GetSession(ctx); return err },
+			status: http.StatusForbidden,
+			code:   apperror.CodePermissionDenied,
+		},
+		{
+			name:   "unknown session",
+			call:   func() error { _, err := c.WithSession("unknown").GetSession(ctx); return err },
+			status: http.StatusUnauthorized,
+			code:   apperror.CodeUnauthenticated,
+		},
+		{
+			name:   "not found",
+			call:   func() error { _, err := c.WithSession(testSessionID).GetUser(ctx, 2); return err },
+			status: http.StatusNotFound,
+			code:   apperror.CodeNotFound,
+		},
+		{
+			name:   "validation",
+			call:   func() error { _, err := c.Login(ctx, "not an email", ""); return err },
+			status: http.StatusUnprocessableEntity,
+			code:   apperror.CodeValidationFailed,
+			fields: 2,
+		},
+		{
+			name: "missing If-Match",
+			call: func() error {
+				_, err := c.WithSession(testSessionID).UpdateUser(ctx, 1, "", &UpdateUserParams{})
+				return err
+			},
+			status: http.StatusPreconditionRequired,
+			code:   apperror.CodePreconditionRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+
+			var apiErr *Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected *Error, got %T: %v", err, err)
+			}
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, apiErr.StatusCode)
+			}
+			if apiErr.RequestID == "" {
+				t.Error("expected the request id")
+			}
+			if code := apperror.CodeOf(err); code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, code)
+			}
+			if fields := apperror.FieldsOf(err); len(fields) != tt.fields {
+				t.Errorf("expected %d invalid fields, got %v", tt.fields, fields)
+			}
+		})
+	}
+}
+
+func TestClient_Introspect(t *testing.T) {
+	svc, ts := newTestServer(t, nil)
+	svc.On("IntrospectToken", mock.Anything, testSessionID).Return(&public.IntrospectionResponse{
+		Active:    true,
+		TokenType: "login",
+		Sub:       "1",
+		UserID:    1,
+		Exp:       1700000000,
+		Scope:     "users:read users:write",
+		Roles:     []string{"user"},
+	}, nil)
+	svc.On("IntrospectToken", mock.Anything, "revoked").Return(&public.IntrospectionResponse{Active: false}, nil)
+
+	ctx := context.Background()
+	for name, opts := range map[string]Options{
+		"client credentials": {ClientID: "test-client", ClientSecret: "test-secret"},
+		"api key":            {APIKey: "test-api-key"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts.HTTPClient = ts.Client()
+			c, err := NewClient(ts.URL, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			introspection, err := c.Introspect(ctx, testSessionID)
+			if err != nil {
+				t.Fatalf("introspect: %v", err)
+			}
+			if !introspection.Active || introspection.UserID != 1 || introspection.Scope != "users:read users:write" || len(introspection.Roles) != 1 {
+				t.Errorf("unexpected introspection: %+v", introspection)
+			}
+
+			introspection, err = c.Introspect(ctx, "revoked")
+			if err != nil {
+				t.Fatalf("introspect: %v", err)
+			}
+			if introspection.Active {
+				t.Errorf("expected revoked token to be inactive, got %+v", introspection)
+			}
+		})
+	}
+
+	for name, opts := range map[string]Options{
+		"no credentials": {},
+		"wrong secret":   {ClientID: "test-client", ClientSecret: "wrong"},
+		"unknown client": {ClientID: "unknown", ClientSecret: "test-secret"},
+		"wrong api key":  {APIKey: "wrong"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			opts.HTTPClient = ts.Client()
+			c, err := NewClient(ts.URL, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = c.WithSession(testSessionID).Introspect(ctx, testSessionID)
+			if code := apperror.CodeOf(err); code != apperror.CodeUnauthenticated {
+				t.Errorf("expected unauthenticated, got %v", err)
+			}
+		})
+	}
+}
+
+// failFirst fails the first n requests with 503
+func failFirst(n int32, attempts *int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(attempts, 1) <= n {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestClient_RetriesIdempotentRequests(t *testing.T) {
+	var attempts int32
+	_, ts := newTestServer(t, failFirst(2, &attempts))
+	c := newTestClient(t, ts).WithSession(testSessionID)
+
+	if _, err := c.GetUser(context.Background(), 1); err != nil {
+		t.Fatalf("expected the request to succeed after the retries, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestClient_DoesNotRetryNonIdempotentRequests(t *testing.T) {
+	var attempts int32
+	_, ts := newTestServer(t, failFirst(1, &attempts))
+	c := newTestClient(t, ts)
+
+	_, err := c.Login(context.Background(), "user@mail.com", "user")
+	if apperror.CodeOf(err) != apperror.CodeUnavailable {
+		t.Fatalf("expected unavailable error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestClient_ContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	_, ts := newTestServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		})
+	})
+	defer close(release)
+	c := newTestClient(t, ts).WithSession(testSessionID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.GetUser(ctx, 1)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the call to stop on cancellation, took %s", elapsed)
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"home24-technical-test/pkg/apperror"
+)
+
+// Error is an error response of the API.
+// It unwraps to the *apperror.Error of its code, so apperror.CodeOf & apperror.FieldsOf work on it
+type Error struct {
+	StatusCode int
+	Code       apperror.Code
+	Title      string
+	Detail     string
+	RequestID  string
+	Fields     []apperror.FieldError
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("user service: %d %s", e.StatusCode, e.Title)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request id %s)", e.RequestID)
+	}
+	return msg
+}
+
+// Unwrap returns the application error of the response
+func (e *Error) Unwrap() error {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+
+	return &apperror.Error{
+		Code:    e.Code,
+		Message: message,
+		Fields:  e.Fields,
+	}
+}
+
+// problem is the RFC 7807 problem details returned by the API
+type problem struct {
+	Title     string                `json:"title"`
+	Status    int                   `json:"status"`
+	Detail    string                `json:"detail"`
+	Code      string                `json:"code"`
+	RequestID string                `json:"requestId"`
+	Errors    []apperror.FieldError `json:"errors"`
+}
+
+// maxErrorBodySize caps how much of an error response is read
+const maxErrorBodySize = 1 << 16
+
+// decodeError builds the *Error of an error response, responses that aren't problem details only get a code from the status
+func decodeError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	var p problem
+	json.Unmarshal(body, &p)
+
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Code:       apperror.Code(p.Code),
+		Title:      p.Title,
+		Detail:     p.Detail,
+		RequestID:  p.RequestID,
+		Fields:     p.Errors,
+	}
+	if e.Title == "" {
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+	if e.Code == "" {
+		e.Code = codeOfStatus(resp.StatusCode)
+	}
+
+	return e
+}
+
+var statusCodes = map[int]apperror.Code{
+	http.StatusBadRequest:            apperror.CodeInvalidArgument,
+	http.StatusUnauthorized:          apperror.CodeUnauthenticated,
+	http.StatusForbidden:             apperror.CodePermissionDenied,
+	http.StatusNotFound:              apperror.CodeNotFound,
+	http.StatusConflict:              apperror.CodeConflict,
+	http.StatusPreconditionFailed:    apperror.CodePreconditionFailed,
+	http.StatusRequestEntityTooLarge: apperror.CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   apperror.CodeValidationFailed,
+	http.StatusUnsupportedMediaType:  apperror.CodeUnsupportedMediaType,
+	http.StatusPreconditionRequired:  apperror.CodePreconditionRequired,
+	http.StatusTooManyRequests:       apperror.CodeTooManyRequests,
+	http.StatusServiceUnavailable:    apperror.CodeUnavailable,
+}
+
+func codeOfStatus(status int) apperror.Code {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return apperror.CodeInternal
+}
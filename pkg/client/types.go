@@ -0,0 +1,123 @@
+package client
+
+import "time"
+
+// User represents a user of the user service
+type User struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Email     string     `json:"email"`
+	Address   string     `json:"address"`
+	CreatedAt time.Time  `json:"createdAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	DeletedBy *int       `json:"deletedBy,omitempty"`
+
+	PhoneNumber           string                 `json:"phoneNumber"`
+	Locale                string                 `json:"locale"`
+	Timezone              string                 `json:"timezone"`
+	MarketingEmailConsent bool                   `json:"marketingEmailConsent"`
+	MarketingSMSConsent   bool                   `json:"marketingSmsConsent"`
+	AvatarURL             string                 `json:"avatarUrl,omitempty"`
+	CustomAttributes      map[string]interface{} `json:"customAttributes"`
+
+	// ETag is the version of the user, pass it to UpdateUser. Empty on listings
+	ETag string `json:"-"`
+}
+
+// SessionUser represents the user data stored in a login session
+type SessionUser struct {
+	Name                  string                 `json:"name"`
+	Email                 string                 `json:"email"`
+	Address               string                 `json:"address"`
+	PhoneNumber           string                 `json:"phoneNumber"`
+	Locale                string                 `json:"locale"`
+	Timezone              string                 `json:"timezone"`
+	MarketingEmailConsent bool                   `json:"marketingEmailConsent"`
+	MarketingSMSConsent   bool                   `json:"marketingSmsConsent"`
+	CustomAttributes      map[string]interface{} `json:"customAttributes"`
+}
+
+// LoginResponse represents the response of login
+type LoginResponse struct {
+	SessionID string       `json:"sessionId"`
+	CSRFToken string       `json:"csrfToken"`
+	ExpiredAt time.Time    `json:"expiredAt"`
+	User      *SessionUser `json:"user"`
+}
+
+// Session represents a login session
+type Session struct {
+	ID        string                 `json:"ID"`
+	Type      string                 `json:"Type"`
+	ExpiredAt time.Time              `json:"ExpiredAt"`
+	Info      map[string]interface{} `json:"Info"`
+	User      *SessionUser           `json:"User"`
+}
+
+// UserID returns the id of the user owning the session
+func (s *Session) UserID() int {
+	id, _ := s.Info["UserID"].(float64)
+	return int(id)
+}
+
+// Sort fields & orders of the users listing
+const (
+	SortByID        = "id"
+	SortByName      = "name"
+	SortByEmail     = "email"
+	SortByCreatedAt = "createdAt"
+
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// ListUsersParams filters & pages the users listing, zero values are left to the server defaults
+type ListUsersParams struct {
+	Search      string
+	Email       string
+	Name        string
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SortBy      string
+	SortOrder   string
+	Limit       int
+	// Cursor is the NextCursor or PrevCursor of the previous page
+	Cursor string
+}
+
+// UsersPage represents a single page of the users listing
+type UsersPage struct {
+	Users      []*User `json:"users"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"nextCursor"`
+	PrevCursor string  `json:"prevCursor"`
+}
+
+// UpdateUserParams represents the changes of a user, empty & nil fields are left untouched
+type UpdateUserParams struct {
+	Name                  string                 `json:"name,omitempty"`
+	Email                 string                 `json:"email,omitempty"`
+	Address               string                 `json:"address,omitempty"`
+	PhoneNumber           *string                `json:"phoneNumber,omitempty"`
+	Locale                *string                `json:"locale,omitempty"`
+	Timezone              *string                `json:"timezone,omitempty"`
+	MarketingEmailConsent *bool                  `json:"marketingEmailConsent,omitempty"`
+	MarketingSMSConsent   *bool                  `json:"marketingSmsConsent,omitempty"`
+	CustomAttributes      map[string]interface{} `json:"customAttributes,omitempty"`
+}
+
+// Introspection represents the introspection of a session token, inactive tokens only have Active set
+type Introspection struct {
+	Active    bool     `json:"active"`
+	TokenType string   `json:"token_type"`
+	Sub       string   `json:"sub"`
+	UserID    int      `json:"user_id"`
+	Exp       int64    `json:"exp"`
+	Scope     string   `json:"scope"`
+	Roles     []string `json:"roles"`
+}
+
+// ExpiresAt returns the expiry of the token
+func (i *Introspection) ExpiresAt() time.Time {
+	return time.Unix(i.Exp, 0)
+}
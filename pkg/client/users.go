@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Login logs the user in. Use WithSession with the returned session id to call the authorized endpoints
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResponse, error) {
+	req, err := newJSONRequest(http.MethodPost, "/v1/login", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var login LoginResponse
+	if err := decodeJSON(resp, &login); err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+// Logout logs the current session out
+func (c *Client) Logout(ctx context.Context) error {
+	resp, err := c.do(ctx, &request{method: http.MethodPost, path: "/v1/logout"})
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// Introspect checks a session token received by the calling service, with the client credentials or the api key of the options.
+// Invalid tokens are not an error, they are reported as inactive
+func (c *Client) Introspect(ctx context.Context, token string) (*Introspection, error) {
+	req := &request{
+		method:      http.MethodPost,
+		path:        "/v1/introspect",
+		body:        []byte(url.Values{"token": []string{token}}.Encode()),
+		contentType: "application/x-www-form-urlencoded",
+		clientAuth:  true,
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var introspection Introspection
+	if err := decodeJSON(resp, &introspection); err != nil {
+		return nil, err
+	}
+	return &introspection, nil
+}
+
+// GetSession gets the current login session
+func (c *Client) GetSession(ctx context.Context) (*Session, error) {
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: "/v1/session"})
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := decodeJSON(resp, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ChangePassword changes the password of the current user
+func (c *Client) ChangePassword(ctx context.Context, oldPassword, newPassword string) error {
+	req, err := newJSONRequest(http.MethodPut, "/v1/users/password", map[string]string{
+		"oldPassword": oldPassword,
+		"newPassword": newPassword,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// ListUsers lists a page of the users
+func (c *Client) ListUsers(ctx context.Context, params *ListUsersParams) (*UsersPage, error) {
+	return c.listUsers(ctx, "/v1/users", params)
+}
+
+// ListDeletedUsers lists a page of the soft deleted users
+func (c *Client) ListDeletedUsers(ctx context.Context, params *ListUsersParams) (*UsersPage, error) {
+	return c.listUsers(ctx, "/v1/users/deleted", params)
+}
+
+func (c *Client) listUsers(ctx context.Context, path string, params *ListUsersParams) (*UsersPage, error) {
+	req := &request{method: http.MethodGet, path: path, query: listUsersQuery(params)}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var page UsersPage
+	if err := decodeJSON(resp, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func listUsersQuery(params *ListUsersParams) url.Values {
+	query := url.Values{}
+	if params == nil {
+		return query
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	set("search", params.Search)
+	set("email", params.Email)
+	set("name", params.Name)
+	set("sortBy", params.SortBy)
+	set("sortOrder", params.SortOrder)
+	set("cursor", params.Cursor)
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.CreatedFrom != nil {
+		query.Set("createdFrom", params.CreatedFrom.Format(time.RFC3339))
+	}
+	if params.CreatedTo != nil {
+		query.Set("createdTo", params.CreatedTo.Format(time.RFC3339))
+	}
+
+	return query
+}
+
+// GetUser gets a user by its id
+func (c *Client) GetUser(ctx context.Context, userID int) (*User, error) {
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: userPath(userID)})
+	if err != nil {
+		return nil, err
+	}
+	return decodeUser(resp)
+}
+
+// UpdateUser updates a user. etag is the ETag of the user the changes are based on, "*" overwrites any version
+func (c *Client) UpdateUser(ctx context.Context, userID int, etag string, params *UpdateUserParams) (*User, error) {
+	req, err := newJSONRequest(http.MethodPut, userPath(userID), params)
+	if err != nil {
+		return nil, err
+	}
+	req.header = http.Header{"If-Match": []string{etag}}
+
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUser(resp)
+}
+
+// RestoreUser restores a soft deleted user
+func (c *Client) RestoreUser(ctx context.Context, userID int) error {
+	resp, err := c.do(ctx, &request{method: http.MethodPut, path: userPath(userID) + "/restore"})
+	if err != nil {
+		return err
+	}
+	discard(resp)
+	return nil
+}
+
+// UploadAvatar uploads the avatar image of a user, it is never retried as the image is streamed once
+func (c *Client) UploadAvatar(ctx context.Context, userID int, filename string, image io.Reader) (*User, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("avatar", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, image); err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %v", err)
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req := &request{
+		method:      http.MethodPost,
+		path:        userPath(userID) + "/avatar",
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+	}
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeUser(resp)
+}
+
+// GetAvatar gets the avatar image of a user, the caller must close the returned reader
+func (c *Client) GetAvatar(ctx context.Context, userID int) (io.ReadCloser, string, error) {
+	resp, err := c.do(ctx, &request{method: http.MethodGet, path: userPath(userID) + "/avatar"})
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+func userPath(userID int) string {
+	return fmt.Sprintf("/v1/users/%d", userID)
+}
+
+func decodeUser(resp *http.Response) (*User, error) {
+	etag := resp.Header.Get("ETag")
+
+	var u User
+	if err := decodeJSON(resp, &u); err != nil {
+		return nil, err
+	}
+	u.ETag = etag
+	return &u, nil
+}
@@ -4,11 +4,14 @@ import (
 	"context"
 	"fmt"
 
+	"home24-technical-test/pkg/apperror"
+
 	"github.com/jmoiron/sqlx"
 )
 
 var (
-	ErrNotFound = fmt.Errorf("data is not found")
+	// ErrNotFound is returned when the requested data does not exist
+	ErrNotFound = apperror.New(apperror.CodeNotFound, "data is not found")
 )
 
 // Manager represents the manager to manage the data consistency
@@ -16,11 +19,16 @@ type Manager struct {
 	db *sqlx.DB
 }
 
-// RunInTransaction runs the f with the transaction queryable inside the context
+// RunInTransaction runs the f with the transaction queryable inside the context.
+// Without a database (the storages are not sql ones) f runs as is
 func (m *Manager) RunInTransaction(ctx context.Context, f func(tctx context.Context) error) error {
-	tx, err := m.db.Beginx()
+	if m.db == nil {
+		return f(ctx)
+	}
+
+	// the transaction is rolled back once ctx is done
+	tx, err := m.db.BeginTxx(ctx, nil)
 	if err != nil {
-		tx.Rollback()
 		return fmt.Errorf("error when creating transction: %v", err)
 	}
 
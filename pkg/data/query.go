@@ -0,0 +1,58 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// The query helpers run a named query (:name parameters bound from a struct or a map) on the transaction
+// of the context, if any, or on db. The rows are always closed, so the connection goes back to the pool
+
+// Get scans the first row of the query into dest, a struct or a scannable value.
+// It returns ErrNotFound when the query has no row
+func Get(ctx context.Context, db *sqlx.DB, dest interface{}, query string, arg interface{}) error {
+	ext := extFromContext(ctx, db)
+	query, args, err := ext.BindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+
+	err = sqlx.GetContext(ctx, ext, dest, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Select scans all the rows of the query into dest, a pointer to a slice
+func Select(ctx context.Context, db *sqlx.DB, dest interface{}, query string, arg interface{}) error {
+	ext := extFromContext(ctx, db)
+	query, args, err := ext.BindNamed(query, arg)
+	if err != nil {
+		return err
+	}
+
+	return sqlx.SelectContext(ctx, ext, dest, query, args...)
+}
+
+// Exec executes the statement, the result tells the rows affected & the last insert id
+func Exec(ctx context.Context, db *sqlx.DB, query string, arg interface{}) (sql.Result, error) {
+	ext := extFromContext(ctx, db)
+	query, args, err := ext.BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	return ext.ExecContext(ctx, query, args...)
+}
+
+// extFromContext returns the transaction of the context, the one of RunInTransaction, or db without transaction
+func extFromContext(ctx context.Context, db *sqlx.DB) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey).(sqlx.ExtContext); ok {
+		return tx
+	}
+	return db
+}
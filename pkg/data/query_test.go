@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type item struct {
+	ID   int    `db:"id"`
+	Name string `db:"name"`
+}
+
+// newTestDB opens a sqlite database holding the items a & b, the test fails when it leaves connections in use
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", filepath.Join(t.TempDir(), "data.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	db.MustExec(`CREATE TABLE item (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`)
+	db.MustExec(`INSERT INTO item (name) VALUES ('a'), ('b')`)
+
+	t.Cleanup(func() {
+		if inUse := db.Stats().InUse; inUse != 0 {
+			t.Errorf("%d database connections leaked", inUse)
+		}
+	})
+
+	return db
+}
+
+func TestGet(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	var found item
+	if err := Get(ctx, db, &found, `SELECT id, name FROM item WHERE name = :name`, map[string]interface{}{"name": "b"}); err != nil {
+		t.Fatalf("failed to get the item: %v", err)
+	}
+	if found.Name != "b" {
+		t.Errorf("expected item b, got %v", found)
+	}
+
+	// the query has more than one row, the first one is scanned
+	var count int
+	if err := Get(ctx, db, &count, `SELECT count(*) FROM item`, map[string]interface{}{}); err != nil {
+		t.Fatalf("failed to count the items: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 items, got %d", count)
+	}
+
+	err := Get(ctx, db, &found, `SELECT id, name FROM item WHERE name = :name`, map[string]interface{}{"name": "c"})
+	if err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	var name int
+	if err := Get(ctx, db, &name, `SELECT name FROM item`, map[string]interface{}{}); err == nil {
+		t.Error("expected the scan to fail")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	items := []*item{}
+	if err := Select(ctx, db, &items, `SELECT id, name FROM item ORDER BY id`, map[string]interface{}{}); err != nil {
+		t.Fatalf("failed to select the items: %v", err)
+	}
+	if len(items) != 2 || items[0].Name != "a" || items[1].Name != "b" {
+		t.Errorf("expected items a & b, got %v", items)
+	}
+
+	names := []string{}
+	err := Select(ctx, db, &names, `SELECT name FROM item WHERE name = :name`, map[string]interface{}{"name": "c"})
+	if err != nil || len(names) != 0 {
+		t.Errorf("expected no item, got %v, %v", names, err)
+	}
+
+	var ids []int
+	if err := Select(ctx, db, &ids, `SELECT name FROM item`, map[string]interface{}{}); err == nil {
+		t.Error("expected the scan to fail")
+	}
+}
+
+func TestExec(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	result, err := Exec(ctx, db, `UPDATE item SET name = :name`, &item{Name: "c"})
+	if err != nil {
+		t.Fatalf("failed to update the items: %v", err)
+	}
+	if affected, _ := result.RowsAffected(); affected != 2 {
+		t.Errorf("expected 2 items updated, got %d", affected)
+	}
+
+	if _, err := Exec(ctx, db, `UPDATE missing SET name = :name`, &item{Name: "c"}); err == nil {
+		t.Error("expected the statement to fail")
+	}
+}
+
+func TestQueriesCancelled(t *testing.T) {
+	db := newTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var found item
+	if err := Get(ctx, db, &found, `SELECT id, name FROM item`, map[string]interface{}{}); err == nil {
+		t.Error("expected the get to be cancelled")
+	}
+	var items []item
+	if err := Select(ctx, db, &items, `SELECT id, name FROM item`, map[string]interface{}{}); err == nil {
+		t.Error("expected the select to be cancelled")
+	}
+	if _, err := Exec(ctx, db, `DELETE FROM item`, map[string]interface{}{}); err == nil {
+		t.Error("expected the exec to be cancelled")
+	}
+}
+
+func TestQueriesInTransaction(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	err := NewManager(db).RunInTransaction(ctx, func(tctx context.Context) error {
+		if _, err := Exec(tctx, db, `INSERT INTO item (name) VALUES (:name)`, &item{Name: "c"}); err != nil {
+			return err
+		}
+
+		// the uncommitted item is only seen by the transaction
+		var found item
+		if err := Get(tctx, db, &found, `SELECT id, name FROM item WHERE name = 'c'`, map[string]interface{}{}); err != nil {
+			t.Errorf("expected the transaction to see its item, got %v", err)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("expected the transaction error, got %v", err)
+	}
+
+	var count int
+	if err := Get(ctx, db, &count, `SELECT count(*) FROM item`, map[string]interface{}{}); err != nil {
+		t.Fatalf("failed to count the items: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected the insert to be rolled back, got %d items", count)
+	}
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies are the networks of the proxies in front of the server. Only they can forward the client ip,
+// the X-Forwarded-For & X-Real-IP headers of any other peer are ignored as anyone can set them
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses the ips & cidrs of the trusted proxies
+func ParseTrustedProxies(addrs []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an ip or a cidr", addr)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an ip or a cidr", addr)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// trusts tells whether the ip is one of a trusted proxy
+func (p TrustedProxies) trusts(ip net.IP) bool {
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the ip of the client. It is the socket peer, unless the peer is a trusted proxy:
+// then it is the last X-Forwarded-For ip not added by a trusted proxy, or X-Real-IP
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer := net.ParseIP(host)
+	if peer == nil || !p.trusts(peer) {
+		return host
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		// the proxies append the ip they received the request from, the ips left of the first
+		// untrusted one are set by the client
+		ips := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(ips) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(ips[i]))
+			if ip == nil {
+				break
+			}
+			peer = ip
+			if !p.trusts(ip) {
+				break
+			}
+		}
+		return peer.String()
+	}
+
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// ByIP limits by client ip, the ip forwarded by the trusted proxies
+func ByIP(proxies TrustedProxies) KeyFunc {
+	return func(r *http.Request) string {
+		return "ip:" + proxies.ClientIP(r)
+	}
+}
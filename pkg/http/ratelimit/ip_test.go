@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if err != nil {
+		t.Fatalf("failed to parse the proxies: %v", err)
+	}
+	if len(proxies) != 3 || proxies[1].String() != "192.168.1.1/32" || proxies[2].String() != "::1/128" {
+		t.Errorf("expected the networks of the proxies, got %v", proxies)
+	}
+
+	for _, addr := range []string{"proxy.internal", "10.0.0.0/33", ""} {
+		if _, err := ParseTrustedProxies([]string{addr}); err == nil {
+			t.Errorf("expected %q to be invalid", addr)
+		}
+	}
+}
+
+func TestTrustedProxies_ClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("failed to parse the proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		proxies    TrustedProxies
+		remoteAddr string
+		headers    map[string]string
+		expected   string
+	}{
+		{"socket peer", proxies, "203.0.113.7:4321", nil, "203.0.113.7"},
+		{"headers of an untrusted peer", proxies, "203.0.113.7:4321", map[string]string{
+			"X-Forwarded-For": "198.51.100.1",
+			"X-Real-IP":       "198.51.100.2",
+		}, "203.0.113.7"},
+		{"no trusted proxy", nil, "10.0.0.1:4321", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "10.0.0.1"},
+		{"forwarded by a trusted proxy", proxies, "10.0.0.1:4321", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed forwarded ips", proxies, "10.0.0.1:4321", map[string]string{
+			"X-Forwarded-For": "192.0.2.1, 198.51.100.1, 10.0.0.2",
+		}, "198.51.100.1"},
+		{"only trusted proxies", proxies, "10.0.0.1:4321", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"real ip of a trusted proxy", proxies, "10.0.0.1:4321", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"invalid real ip", proxies, "10.0.0.1:4321", map[string]string{"X-Real-IP": "client"}, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			if ip := tt.proxies.ClientIP(r); ip != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, ip)
+			}
+			if key := ByIP(tt.proxies)(r); key != "ip:"+tt.expected {
+				t.Errorf("expected the key of %s, got %s", tt.expected, key)
+			}
+		})
+	}
+}
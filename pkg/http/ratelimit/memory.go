@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// bucket is the token bucket of a single key
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// MemoryLimiter keeps the buckets in process, the limits are per instance
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// Allow takes a token of the bucket of key
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(policy.rate()), policy.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	allowed := b.limiter.AllowN(now, 1)
+	return newResult(allowed, b.limiter.TokensAt(now), policy), nil
+}
+
+// sweepInterval is how often the idle buckets are dropped
+const sweepInterval = time.Minute
+
+// sweep drops the buckets idle for long enough to be full again, the caller must hold the lock
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.limiter.TokensAt(now) >= float64(b.limiter.Burst()) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// NewMemoryLimiter creates a new in process limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testPolicy allows a burst of 2 requests, refilled every 20ms
+var testPolicy = Policy{Name: "test", Requests: 1, Per: 20 * time.Millisecond, Burst: 2}
+
+// checkBucket takes the burst of the bucket of key, checks the next request is rejected until a token is refilled
+func checkBucket(t *testing.T, limiter Limiter, key string) {
+	t.Helper()
+	ctx := context.Background()
+
+	for i := 0; i < testPolicy.Burst; i++ {
+		result, err := limiter.Allow(ctx, key, testPolicy)
+		if err != nil {
+			t.Fatalf("failed to take a token: %v", err)
+		}
+		if !result.Allowed || result.Limit != testPolicy.Burst || result.Remaining != testPolicy.Burst-1-i {
+			t.Fatalf("expected request %d of the burst to be allowed, got %+v", i+1, result)
+		}
+		if result.RetryAfter != 0 || result.Reset <= 0 || result.Reset > time.Duration(i+1)*testPolicy.Per {
+			t.Errorf("expected the bucket to be full again after %d refills, got %+v", i+1, result)
+		}
+	}
+
+	result, err := limiter.Allow(ctx, key, testPolicy)
+	if err != nil {
+		t.Fatalf("failed to take a token: %v", err)
+	}
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected the request past the burst to be rejected, got %+v", result)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > testPolicy.Per {
+		t.Errorf("expected to retry within a refill, got %v", result.RetryAfter)
+	}
+
+	time.Sleep(result.RetryAfter + 5*time.Millisecond)
+	result, err = limiter.Allow(ctx, key, testPolicy)
+	if err != nil {
+		t.Fatalf("failed to take a token: %v", err)
+	}
+	if !result.Allowed {
+		t.Errorf("expected the refilled token to be allowed, got %+v", result)
+	}
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	limiter := NewMemoryLimiter()
+
+	checkBucket(t, limiter, "a")
+	// the buckets are per key
+	checkBucket(t, limiter, "b")
+}
+
+func TestMemoryLimiter_Sweep(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	if _, err := limiter.Allow(context.Background(), "a", testPolicy); err != nil {
+		t.Fatalf("failed to take a token: %v", err)
+	}
+
+	// the bucket is full again once the sweep runs
+	limiter.sweep(time.Now().Add(sweepInterval))
+	if len(limiter.buckets) != 0 {
+		t.Errorf("expected the full bucket to be dropped, got %d buckets", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"home24-technical-test/pkg/appcontext"
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/http/response"
+)
+
+// Policy is a token bucket: Burst requests at once, refilled at Requests per Per
+type Policy struct {
+	// Name namespaces the buckets of the policy, routes sharing a name share their buckets
+	Name     string
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// rate returns the refill rate in tokens per second
+func (p Policy) rate() float64 {
+	return float64(p.Requests) / p.Per.Seconds()
+}
+
+// ParsePolicy parses a "requests/period" policy, e.g. "10/1m". The burst is the number of requests
+func ParsePolicy(name, policy string) (Policy, error) {
+	parts := strings.SplitN(policy, "/", 2)
+	if len(parts) != 2 {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected requests/period", policy)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit requests %q", parts[0])
+	}
+	per, err := time.ParseDuration(parts[1])
+	if err != nil || per <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit period %q", parts[1])
+	}
+
+	return Policy{Name: name, Requests: requests, Per: per, Burst: requests}, nil
+}
+
+// Result is the outcome of a single rate limit check
+type Result struct {
+	Allowed bool
+	// Limit is the burst of the policy
+	Limit int
+	// Remaining is the number of requests left right now
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token of the bucket of key
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// KeyFunc returns the client the request is limited by, empty to skip the request
+type KeyFunc func(r *http.Request) string
+
+// ByUserID limits by the authorized user, requests without a user are skipped
+func ByUserID(r *http.Request) string {
+	userID := appcontext.UserID(r.Context())
+	if userID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// ByAPIKey limits by the api key, the basic auth client id or the client certificate identity,
+// requests without them are skipped. The key is hashed so the secrets don't end in the backend
+func ByAPIKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if clientID, _, ok := r.BasicAuth(); ok {
+		key = "client:" + clientID
+	}
+	if identity := appcontext.ServiceIdentity(r.Context()); identity != "" {
+		key = "service:" + identity
+	}
+	if key == "" {
+		return ""
+	}
+	return fmt.Sprintf("key:%x", sha256.Sum256([]byte(key)))
+}
+
+// ErrRateLimited is returned when a client exceeds its rate limit
+var ErrRateLimited = apperror.New(apperror.CodeTooManyRequests, "rate limit exceeded")
+
+// Middleware limits the requests with the policy, once per key func.
+// A request is rejected with 429 as soon as one of its keys is out of tokens.
+// The RateLimit headers describe the most restrictive key, the limiter failing lets the requests through
+func Middleware(limiter Limiter, policy Policy, keyFuncs ...KeyFunc) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			var tightest *Result
+			for _, keyFunc := range keyFuncs {
+				key := keyFunc(r)
+				if key == "" {
+					continue
+				}
+
+				result, err := limiter.Allow(r.Context(), policy.Name+":"+key, policy)
+				if err != nil {
+					log.Printf("Error: [%s] rate limiter: %v\n", policy.Name, err)
+					continue
+				}
+				if tightest == nil || !result.Allowed || (tightest.Allowed && result.Remaining < tightest.Remaining) {
+					tightest = &result
+				}
+				if !result.Allowed {
+					break
+				}
+			}
+
+			if tightest != nil {
+				setHeaders(w, policy, *tightest)
+				if !tightest.Allowed {
+					w.Header().Set("Retry-After", strconv.Itoa(seconds(tightest.RetryAfter)))
+					response.Error(w, r, ErrRateLimited)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// setHeaders writes the RateLimit headers of the IETF draft
+func setHeaders(w http.ResponseWriter, policy Policy, result Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Burst, seconds(policy.Per)))
+}
+
+// seconds rounds the duration up to whole seconds
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// newResult builds the result of a bucket left with tokens
+func newResult(allowed bool, tokens float64, policy Policy) Result {
+	rate := policy.rate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Burst,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     time.Duration((float64(policy.Burst) - tokens) / rate * float64(time.Second)),
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	return result
+}
+
+var errUnexpectedReply = errors.New("unexpected reply of the rate limit script")
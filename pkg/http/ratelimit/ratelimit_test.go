@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// failingLimiter is a limiter whose backend is down
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	return Result{}, errors.New("limiter is down")
+}
+
+// byHeader limits by the X-Client header
+func byHeader(r *http.Request) string {
+	return r.Header.Get("X-Client")
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("login", "10/1m")
+	if err != nil {
+		t.Fatalf("failed to parse the policy: %v", err)
+	}
+	if policy != (Policy{Name: "login", Requests: 10, Per: time.Minute, Burst: 10}) {
+		t.Errorf("expected 10 requests per minute, got %+v", policy)
+	}
+
+	for _, invalid := range []string{"10", "ten/1m", "0/1m", "10/minute", "10/0s"} {
+		if _, err := ParsePolicy("login", invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	policy := Policy{Name: "test", Requests: 2, Per: time.Minute, Burst: 2}
+	handler := Middleware(NewMemoryLimiter(), policy, byHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(client string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if client != "" {
+			r.Header.Set("X-Client", client)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	for i := 0; i < policy.Burst; i++ {
+		w := serve("a")
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected request %d to pass, got %d", i+1, w.Code)
+		}
+		if w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(policy.Burst-1-i) ||
+			w.Header().Get("RateLimit-Policy") != "2;w=60" || w.Header().Get("Retry-After") != "" {
+			t.Errorf("expected the RateLimit headers of request %d, got %v", i+1, w.Header())
+		}
+	}
+
+	w := serve("a")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the request past the burst to be rejected, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Remaining") != "0" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("expected to retry after the next refill, got %v", w.Header())
+	}
+
+	// the other clients have their own bucket, the requests without key aren't limited
+	if w := serve("b"); w.Code != http.StatusNoContent {
+		t.Errorf("expected another client to pass, got %d", w.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if w := serve(""); w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("expected the request without key to pass without headers, got %d %v", w.Code, w.Header())
+		}
+	}
+}
+
+func TestMiddleware_TightestKey(t *testing.T) {
+	policy := Policy{Name: "test", Requests: 1, Per: time.Minute, Burst: 1}
+	byIP := ByIP(nil)
+	handler := Middleware(NewMemoryLimiter(), policy, byIP, byHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Client", "a")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected the first request to pass, got %d", w.Code)
+	}
+
+	// same ip, another key: the ip bucket is out of tokens
+	r.Header.Set("X-Client", "b")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the exhausted ip to be rejected, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestMiddleware_LimiterFailure(t *testing.T) {
+	policy := Policy{Name: "test", Requests: 1, Per: time.Minute, Burst: 1}
+	handler := Middleware(failingLimiter{}, policy, byHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Client", "a")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusNoContent || w.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("expected the requests to pass when the limiter fails, got %d %v", w.Code, w.Header())
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// tokenBucket takes a token of the bucket KEYS[1], it returns whether it was allowed & the tokens left.
+// ARGV: refill rate per second, burst, now in seconds
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HMSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000))
+
+return {allowed, tostring(tokens)}
+`)
+
+// RedisLimiter keeps the buckets in redis, the limits are shared by the instances
+type RedisLimiter struct {
+	redisClient redis.UniversalClient
+	prefix      string
+}
+
+// Allow takes a token of the bucket of key
+func (l *RedisLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+
+	res, err := tokenBucket.Run(l.redisClient, []string{l.prefix + key}, policy.rate(), policy.Burst, now).Result()
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, errUnexpectedReply
+	}
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return Result{}, errUnexpectedReply
+	}
+
+	return newResult(allowed == 1, tokens, policy), nil
+}
+
+// NewRedisLimiter creates a new limiter keeping its buckets under "ratelimit:" in redis
+func NewRedisLimiter(redisClient redis.UniversalClient) *RedisLimiter {
+	return &RedisLimiter{
+		redisClient: redisClient,
+		prefix:      "ratelimit:",
+	}
+}
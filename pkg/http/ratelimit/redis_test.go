@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+// newTestRedisLimiter runs the token bucket script on an in process miniredis
+func newTestRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	redisClient := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	return NewRedisLimiter(redisClient), server
+}
+
+func TestRedisLimiter(t *testing.T) {
+	limiter, server := newTestRedisLimiter(t)
+
+	checkBucket(t, limiter, "a")
+	checkBucket(t, limiter, "b")
+
+	// the idle buckets expire once full again
+	ttl := server.TTL("ratelimit:a")
+	if ttl <= 0 || ttl > testPolicy.Per*2 {
+		t.Errorf("expected the bucket to expire once refilled, got a ttl of %v", ttl)
+	}
+}
+
+func TestRedisLimiter_Unavailable(t *testing.T) {
+	limiter, server := newTestRedisLimiter(t)
+	server.Close()
+
+	if _, err := limiter.Allow(context.Background(), "a", testPolicy); err == nil {
+		t.Error("expected the limiter to fail without redis")
+	}
+}
@@ -0,0 +1,64 @@
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"home24-technical-test/pkg/apperror"
+	"home24-technical-test/pkg/validation"
+)
+
+// MaxBodySize is the maximum size of a json request body
+const MaxBodySize = 1 << 20
+
+var (
+	errBodyTooLarge = apperror.New(apperror.CodePayloadTooLarge, "request body is too large")
+	errEmptyBody    = apperror.New(apperror.CodeInvalidArgument, "request body is empty")
+	errTrailingData = apperror.New(apperror.CodeInvalidArgument, "request body must contain a single json object")
+)
+
+// DecodeJSON decodes the json request body into dst & validates it.
+// Unknown fields & failed validations are reported as validation errors listing the invalid fields
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		return errTrailingData
+	}
+
+	return validation.Validate(dst)
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return errBodyTooLarge
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.As(err, &typeErr):
+		return apperror.NewValidation([]apperror.FieldError{{
+			Field:   typeErr.Field,
+			Message: "has the wrong type",
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return apperror.NewValidation([]apperror.FieldError{{
+			Field:   field,
+			Message: "is not allowed",
+		}})
+	}
+
+	return apperror.Wrap(err, apperror.CodeInvalidArgument, "malformed json")
+}
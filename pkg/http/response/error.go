@@ -5,30 +5,57 @@ import (
 	"fmt"
 	"net/http"
 
+	"home24-technical-test/pkg/apperror"
+
+	"github.com/go-chi/chi/middleware"
 	"github.com/pkg/errors"
 )
 
-// Error writes error http response
-func Error(w http.ResponseWriter, data string, status int, err error) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+// Problem represents the RFC 7807 problem details of an error response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"requestId,omitempty"`
+	// Errors lists the invalid fields of validation errors
+	Errors []apperror.FieldError `json:"errors,omitempty"`
+}
 
-	json.NewEncoder(w).Encode(Response{
-		Code: status,
-		Info: data,
-	})
+// Error writes the error as an application/problem+json response.
+// It is the single place where errors are mapped to http responses, the status comes from the apperror code
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	status := apperror.HTTPStatus(err)
 
-	if err != nil {
-		type stackTracer interface {
-			StackTrace() errors.StackTrace
-		}
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.Path,
+		Code:      string(apperror.CodeOf(err)),
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    apperror.FieldsOf(err),
+	}
+	// the cause of server errors is only logged, it may leak internals
+	if status < http.StatusInternalServerError {
+		problem.Detail = err.Error()
+	}
 
-		var st errors.StackTrace
-		if err, ok := err.(stackTracer); ok {
-			st = err.StackTrace()
-			fmt.Printf("INFO: %+v\n", st[0])
-		}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+
+	type stackTracer interface {
+		StackTrace() errors.StackTrace
+	}
 
-		fmt.Printf("Error: %v\n", err)
+	var st errors.StackTrace
+	if err, ok := err.(stackTracer); ok {
+		st = err.StackTrace()
+		fmt.Printf("INFO: %+v\n", st[0])
 	}
+
+	fmt.Printf("Error: [%s] %v\n", problem.RequestID, err)
 }
@@ -12,8 +12,13 @@ type Response struct {
 	Info string      `json:"info"`
 }
 
-// JSON writes json http response
+// JSON writes json http response, responses without content are written without a body
 func JSON(w http.ResponseWriter, status int, data interface{}) {
+	if status == http.StatusNoContent || status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
@@ -0,0 +1,257 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// server is a listener served until it's shut down
+type server struct {
+	name     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+// worker is a background job running until its context is done
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// closer is a dependency closed once nothing uses it anymore
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager runs the servers & the background workers of the process until SIGINT or SIGTERM,
+// then shuts them down within the shutdown timeout and closes the dependencies
+type Manager struct {
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	servers []server
+	workers []worker
+	closers []closer
+
+	ready int32
+}
+
+// Serve registers a server, serve blocks until shutdown is called.
+// serve returning http.ErrServerClosed (or any error once shut down) is expected and ignored
+func (m *Manager) Serve(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	m.servers = append(m.servers, server{name: name, serve: serve, shutdown: shutdown})
+}
+
+// Go registers a background worker, its context is cancelled on shutdown and it has to return then
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	m.workers = append(m.workers, worker{name: name, run: run})
+}
+
+// OnClose registers a dependency closed after the servers & the workers stopped,
+// the dependencies are closed in the order they were registered
+func (m *Manager) OnClose(name string, close func() error) {
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Ready tells whether the process takes traffic, it's false before Run and once the shutdown started
+func (m *Manager) Ready() bool {
+	return atomic.LoadInt32(&m.ready) == 1
+}
+
+// Run starts the servers & the workers and blocks until SIGINT, SIGTERM, ctx is done or one of them fails.
+// It then stops taking traffic (readiness is flipped and the servers are drained after the drain delay),
+// stops the workers and closes the dependencies. The errors of all the steps are returned
+func (m *Manager) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	var shuttingDown int32
+	failed := make(chan error, len(m.servers)+len(m.workers))
+	var running sync.WaitGroup
+
+	for _, srv := range m.servers {
+		srv := srv
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			if err := srv.serve(); err != nil && atomic.LoadInt32(&shuttingDown) == 0 {
+				failed <- fmt.Errorf("%s: %v", srv.name, err)
+			}
+		}()
+	}
+
+	var workers sync.WaitGroup
+	for _, w := range m.workers {
+		w := w
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := w.run(workersCtx); err != nil && !errors.Is(err, context.Canceled) {
+				failed <- fmt.Errorf("%s: %v", w.name, err)
+			}
+		}()
+	}
+
+	atomic.StoreInt32(&m.ready, 1)
+
+	var errs []error
+	select {
+	case sig := <-signals:
+		log.Printf("Received %s, shutting down ...", sig)
+	case <-ctx.Done():
+		log.Println("Shutting down ...")
+	case err := <-failed:
+		log.Printf("Shutting down after a failure: %v", err)
+		errs = append(errs, err)
+	}
+
+	// the load balancers stop sending new requests once the readiness probe fails
+	atomic.StoreInt32(&m.ready, 0)
+	atomic.StoreInt32(&shuttingDown, 1)
+	if m.drainDelay > 0 {
+		time.Sleep(m.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	errs = append(errs, m.shutdownServers(shutdownCtx)...)
+
+	stopWorkers()
+	if err := wait(shutdownCtx, &workers); err != nil {
+		errs = append(errs, fmt.Errorf("workers: %v", err))
+	}
+	if err := wait(shutdownCtx, &running); err != nil {
+		errs = append(errs, fmt.Errorf("servers: %v", err))
+	}
+
+	// the failures of the other components while shutting down
+	errs = append(errs, drain(failed)...)
+	errs = append(errs, m.closeAll()...)
+
+	log.Println("Server exiting")
+	return joinErrors(errs)
+}
+
+// Close shuts down the servers and closes the dependencies without running them,
+// it cleans up what was registered when the start failed before Run
+func (m *Manager) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	errs := m.shutdownServers(ctx)
+	errs = append(errs, m.closeAll()...)
+	return joinErrors(errs)
+}
+
+// closeAll closes the dependencies in the order they were registered
+func (m *Manager) closeAll() []error {
+	var errs []error
+	for _, c := range m.closers {
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %v", c.name, err))
+		}
+	}
+	return errs
+}
+
+// shutdownServers drains the servers concurrently
+func (m *Manager) shutdownServers(ctx context.Context) []error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+
+	for _, srv := range m.servers {
+		srv := srv
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("shutdown %s: %v", srv.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// wait waits for the wait group until ctx is done
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain receives the errors already sent on the channel
+func drain(errc chan error) []error {
+	var errs []error
+	for {
+		select {
+		case err := <-errc:
+			errs = append(errs, err)
+		default:
+			return errs
+		}
+	}
+}
+
+// shutdownErrors are the errors of a shutdown
+type shutdownErrors []error
+
+func (se shutdownErrors) Error() string {
+	messages := make([]string, len(se))
+	for i, err := range se {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return shutdownErrors(errs)
+}
+
+// NewManager creates a new lifecycle manager, the servers & the workers have shutdownTimeout to stop
+// once the drain delay passed
+func NewManager(
+	shutdownTimeout time.Duration,
+	drainDelay time.Duration,
+) *Manager {
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+		drainDelay:      drainDelay,
+	}
+}
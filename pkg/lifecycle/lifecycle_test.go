@@ -0,0 +1,266 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// events records the steps of the lifecycle in order
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(format string, args ...interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, fmt.Sprintf(format, args...))
+}
+
+func (e *events) String() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.Join(e.list, ", ")
+}
+
+// fakeServer blocks in serve until it's shut down, like http.Server
+type fakeServer struct {
+	name    string
+	events  *events
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func newFakeServer(name string, ev *events) *fakeServer {
+	return &fakeServer{name: name, events: ev, stopped: make(chan struct{})}
+}
+
+func (s *fakeServer) serve() error {
+	<-s.stopped
+	return errors.New("server closed")
+}
+
+func (s *fakeServer) shutdown(ctx context.Context) error {
+	s.events.add("shutdown %s", s.name)
+	s.once.Do(func() { close(s.stopped) })
+	return nil
+}
+
+// runManager runs m in the background, the returned channel receives the error of Run
+func runManager(t *testing.T, m *Manager, ctx context.Context) <-chan error {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !m.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the manager to be ready")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return done
+}
+
+// waitRun waits for the error of Run
+func waitRun(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected Run to return")
+		return nil
+	}
+}
+
+func TestManager_SIGTERM(t *testing.T) {
+	ev := &events{}
+	m := NewManager(time.Second, 0)
+	srv := newFakeServer("http", ev)
+	m.Serve("http", srv.serve, srv.shutdown)
+
+	done := runManager(t, m, context.Background())
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("failed to send SIGTERM: %v", err)
+	}
+
+	if err := waitRun(t, done); err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if ev.String() != "shutdown http" {
+		t.Errorf("expected the server to be shut down, got %s", ev)
+	}
+}
+
+func TestManager_Readiness(t *testing.T) {
+	ev := &events{}
+	drainDelay := 50 * time.Millisecond
+	m := NewManager(time.Second, drainDelay)
+	if m.Ready() {
+		t.Fatal("expected the manager not to be ready before Run")
+	}
+
+	var stoppedAt time.Time
+	srv := newFakeServer("http", ev)
+	m.Serve("http", srv.serve, func(ctx context.Context) error {
+		ev.add("ready %v", m.Ready())
+		if time.Since(stoppedAt) < drainDelay {
+			ev.add("drained too early")
+		}
+		return srv.shutdown(ctx)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runManager(t, m, ctx)
+
+	stoppedAt = time.Now()
+	cancel()
+	time.Sleep(drainDelay / 2)
+	if m.Ready() {
+		t.Error("expected the readiness to flip before the drain delay passed")
+	}
+
+	if err := waitRun(t, done); err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if ev.String() != "ready false, shutdown http" {
+		t.Errorf("expected the server to be drained once not ready, got %s", ev)
+	}
+}
+
+func TestManager_DrainDeadline(t *testing.T) {
+	m := NewManager(30*time.Millisecond, 0)
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	m.Serve("http", func() error {
+		<-stuck
+		return nil
+	}, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	m.Go("worker", func(ctx context.Context) error {
+		<-stuck
+		return nil
+	})
+	closed := false
+	m.OnClose("db", func() error {
+		closed = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runManager(t, m, ctx)
+	started := time.Now()
+	cancel()
+
+	err := waitRun(t, done)
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected the shutdown to give up after the timeout, took %v", elapsed)
+	}
+	for _, expected := range []string{
+		"shutdown http: context deadline exceeded",
+		"workers: context deadline exceeded",
+		"servers: context deadline exceeded",
+	} {
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected %q in the error, got %v", expected, err)
+		}
+	}
+	if !closed {
+		t.Error("expected the dependencies to be closed after the deadline")
+	}
+}
+
+func TestManager_CloseOrder(t *testing.T) {
+	ev := &events{}
+	m := NewManager(time.Second, 0)
+
+	srv := newFakeServer("http", ev)
+	m.Serve("http", srv.serve, srv.shutdown)
+	m.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		ev.add("worker stopped")
+		return ctx.Err()
+	})
+	m.OnClose("db", func() error {
+		ev.add("close db")
+		return nil
+	})
+	m.OnClose("redis", func() error {
+		ev.add("close redis")
+		return errors.New("already closed")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := runManager(t, m, ctx)
+	cancel()
+
+	err := waitRun(t, done)
+	if err == nil || err.Error() != "close redis: already closed" {
+		t.Errorf("expected the close error, got %v", err)
+	}
+	if ev.String() != "shutdown http, worker stopped, close db, close redis" {
+		t.Errorf("expected the dependencies to be closed in order once stopped, got %s", ev)
+	}
+}
+
+func TestManager_Failure(t *testing.T) {
+	ev := &events{}
+	m := NewManager(time.Second, 0)
+
+	srv := newFakeServer("http", ev)
+	m.Serve("http", srv.serve, srv.shutdown)
+	failing := make(chan struct{})
+	m.Go("subscriber", func(ctx context.Context) error {
+		<-failing
+		return errors.New("connection lost")
+	})
+
+	done := runManager(t, m, context.Background())
+	close(failing)
+
+	err := waitRun(t, done)
+	if err == nil || err.Error() != "subscriber: connection lost" {
+		t.Errorf("expected the failure of the worker, got %v", err)
+	}
+	if ev.String() != "shutdown http" {
+		t.Errorf("expected the server to be shut down after the failure, got %s", ev)
+	}
+}
+
+func TestManager_Close(t *testing.T) {
+	ev := &events{}
+	m := NewManager(time.Second, 0)
+
+	srv := newFakeServer("http", ev)
+	m.Serve("http", srv.serve, srv.shutdown)
+	m.Go("worker", func(ctx context.Context) error {
+		ev.add("worker started")
+		return nil
+	})
+	m.OnClose("db", func() error {
+		ev.add("close db")
+		return nil
+	})
+
+	if err := m.Close(); err != nil {
+		t.Errorf("expected a clean close, got %v", err)
+	}
+	if ev.String() != "shutdown http, close db" {
+		t.Errorf("expected the servers to be shut down and the dependencies closed, got %s", ev)
+	}
+}
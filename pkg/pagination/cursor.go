@@ -0,0 +1,46 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"home24-technical-test/pkg/apperror"
+)
+
+// ErrInvalidCursor is returned when a cursor can not be decoded
+var ErrInvalidCursor = apperror.New(apperror.CodeInvalidArgument, "invalid cursor")
+
+// Cursor represents the position of a record in a keyset paginated listing
+type Cursor struct {
+	// SortBy is the sort field the cursor was created for
+	SortBy string `json:"s"`
+	// SortOrder is the sort order the cursor was created for
+	SortOrder string `json:"o"`
+	// Value is the sort field value of the record
+	Value string `json:"v,omitempty"`
+	// ID is the record id, used as the tie breaker
+	ID int `json:"id"`
+	// Backward tells to page backward from the record
+	Backward bool `json:"b,omitempty"`
+}
+
+// Encode encodes the cursor into an opaque string
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes the opaque string created by Encode
+func DecodeCursor(encoded string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"home24-technical-test/pkg/apperror"
+)
+
+// emailPattern is a pragmatic email check, the address is only really verified by sending to it
+var emailPattern = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// Validate validates the struct fields against their `validate` tags & returns an apperror
+// listing every invalid field. Supported rules are required, email, min=n and max=n,
+// min & max count the characters of strings. Nil pointers & empty strings are only checked by required
+func Validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []apperror.FieldError
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		if message := validateField(value.Field(i), strings.Split(tag, ",")); message != "" {
+			fields = append(fields, apperror.FieldError{
+				Field:   FieldName(field),
+				Message: message,
+			})
+		}
+	}
+
+	if len(fields) > 0 {
+		return apperror.NewValidation(fields)
+	}
+	return nil
+}
+
+// FieldName returns the json name of the struct field
+func FieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func validateField(value reflect.Value, rules []string) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if hasRule(rules, "required") {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if isZero(value) {
+		if hasRule(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "email":
+			if value.Kind() == reflect.String && !emailPattern.MatchString(value.String()) {
+				return "must be a valid email address"
+			}
+		case "min":
+			limit, _ := strconv.Atoi(arg)
+			if size(value) < limit {
+				return lengthMessage(value, "at least", limit)
+			}
+		case "max":
+			limit, _ := strconv.Atoi(arg)
+			if size(value) > limit {
+				return lengthMessage(value, "at most", limit)
+			}
+		}
+	}
+
+	return ""
+}
+
+func hasRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Map, reflect.Slice:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func size(value reflect.Value) int {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String())
+	case reflect.Map, reflect.Slice:
+		return value.Len()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int())
+	}
+	return 0
+}
+
+func lengthMessage(value reflect.Value, bound string, limit int) string {
+	switch value.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("must be %s %d characters", bound, limit)
+	case reflect.Map, reflect.Slice:
+		return fmt.Sprintf("must have %s %d items", bound, limit)
+	}
+	return fmt.Sprintf("must be %s %d", bound, limit)
+}